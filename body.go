@@ -0,0 +1,163 @@
+package sentrygin
+
+import (
+	"bytes"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// maxFormBytes is the maximum size of a form body parsed by captureFormFieldNames.
+const maxFormBytes = 1 << 20
+
+// readCloser combines an io.Reader and an io.Closer to implement io.ReadCloser.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyBufferKey is the gin.Context key under which the buffer of BufferRequestBody is stored.
+const bodyBufferKey = "sentrygin.body_buffer"
+
+// bodyBuffer holds the bytes of a request body buffered by BufferRequestBody.
+type bodyBuffer struct {
+	data []byte
+	// rest is the original body, positioned right after the buffered bytes.
+	rest io.ReadCloser
+	// eof is set once rest has been read to its end, or has failed.
+	eof bool
+	err error
+	// drained is set once rest has been read by the handlers, the buffer can't be extended afterwards.
+	drained bool
+}
+
+// Read reads the remainder of the original body on behalf of the handlers.
+func (b *bodyBuffer) Read(p []byte) (int, error) {
+	b.drained = true
+	return b.rest.Read(p)
+}
+
+// BufferRequestBody reads up to limit bytes of the request body, caches them on the context and resets
+// c.Request.Body, so it yields the buffered bytes followed by the remainder of the body.
+// Subsequent calls reuse the cached buffer, so several middlewares can read the same body.
+//
+// The remainder of a body longer than the buffer can be read only once: calling BufferRequestBody with a greater
+// limit after the handlers have read past the buffered bytes returns the buffered bytes only.
+// A negative limit is treated as 0.
+func BufferRequestBody(c *gin.Context, limit int) ([]byte, error) {
+	if limit < 0 {
+		limit = 0
+	}
+
+	r := c.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	buf, _ := c.Value(bodyBufferKey).(*bodyBuffer)
+	if buf == nil {
+		buf = &bodyBuffer{rest: r.Body}
+		c.Set(bodyBufferKey, buf)
+	}
+
+	if missing := limit - len(buf.data); missing > 0 && !buf.eof && !buf.drained {
+		data, err := io.ReadAll(io.LimitReader(buf.rest, int64(missing)))
+		buf.data = append(buf.data, data...)
+		buf.eof = len(data) < missing || err != nil
+		buf.err = err
+	}
+
+	r.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(buf.data), buf),
+		Closer: buf.rest,
+	}
+
+	data := buf.data
+	if len(data) > limit {
+		data = data[:limit]
+	}
+
+	return data, buf.err
+}
+
+// captureRequestBody reads up to limit bytes of the request body and attaches them to the scope.
+// The request body is restored, so the downstream handlers can still read it in full.
+func captureRequestBody(c *gin.Context, scope *sentry.Scope, limit int) {
+	if isStreamingContentType(c.ContentType()) {
+		return
+	}
+
+	// One extra byte is read to find out whether the body has to be truncated.
+	data, err := BufferRequestBody(c, limit+1)
+	if err != nil || len(data) == 0 {
+		return
+	}
+
+	truncated := len(data) > limit
+	if truncated {
+		data = data[:limit]
+	}
+
+	scope.SetRequestBody(data)
+	if truncated {
+		scope.SetExtra("request_body_truncated", true)
+	}
+}
+
+func isStreamingContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "multipart/") || contentType == "application/octet-stream"
+}
+
+// captureFormFieldNames attaches the names of the fields of a form body to the scope.
+// The body is parsed from the buffer of BufferRequestBody, so the downstream handlers can still read it in full.
+func captureFormFieldNames(c *gin.Context, scope *sentry.Scope) {
+	r := c.Request
+	contentType := c.ContentType()
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength > maxFormBytes ||
+		(contentType != gin.MIMEPOSTForm && contentType != gin.MIMEMultipartPOSTForm) {
+		return
+	}
+
+	data, err := BufferRequestBody(c, maxFormBytes+1)
+	if err != nil || len(data) > maxFormBytes {
+		return
+	}
+
+	form := &http.Request{
+		Method:        r.Method,
+		URL:           &url.URL{},
+		Header:        r.Header,
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+	}
+	names := make(map[string]struct{})
+	if contentType == gin.MIMEMultipartPOSTForm {
+		if err := form.ParseMultipartForm(maxFormBytes); err != nil {
+			return
+		}
+		defer form.MultipartForm.RemoveAll()
+		for name := range form.MultipartForm.File {
+			names[name] = struct{}{}
+		}
+	} else if err := form.ParseForm(); err != nil {
+		return
+	}
+	for name := range form.PostForm {
+		names[name] = struct{}{}
+	}
+
+	if len(names) == 0 {
+		return
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	scope.SetExtra("form_field_names", sorted)
+}
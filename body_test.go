@@ -0,0 +1,168 @@
+package sentrygin
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCaptureFormFieldNames(t *testing.T) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("name", "Jane")
+	_ = w.WriteField("email", "jane@example.com")
+	file, _ := w.CreateFormFile("avatar", "avatar.png")
+	_, _ = file.Write([]byte("not really a png"))
+	_ = w.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string
+	}{
+		{
+			name:        "multipart",
+			contentType: w.FormDataContentType(),
+			body:        body.String(),
+			want:        []string{"avatar", "email", "name"},
+		},
+		{
+			name:        "urlencoded",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=Jane&email=jane%40example.com",
+			want:        []string{"email", "name"},
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"name":"Jane"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{CaptureFormFieldNames: true})
+			var read string
+			r.POST("/users", func(c *gin.Context) {
+				var buf bytes.Buffer
+				_, _ = buf.ReadFrom(c.Request.Body)
+				read = buf.String()
+				panic("boom")
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			serveRequest(r, req)
+
+			if read != tt.body {
+				t.Errorf("the handler read %q, want the whole body %q", read, tt.body)
+			}
+			got, ok := onlyEvent(t, transport).Extra["form_field_names"]
+			if tt.want == nil {
+				if ok {
+					t.Errorf("form_field_names = %v, want none", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("form_field_names = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBufferRequestBody(t *testing.T) {
+	const body = "0123456789"
+
+	tests := []struct {
+		name   string
+		limits []int
+		want   []string
+	}{
+		{name: "sequential reads", limits: []int{4, 4}, want: []string{"0123", "0123"}},
+		{name: "extended buffer", limits: []int{4, 6}, want: []string{"0123", "012345"}},
+		{name: "shorter limit", limits: []int{6, 2}, want: []string{"012345", "01"}},
+		{name: "whole body", limits: []int{64, 64}, want: []string{body, body}},
+		{name: "negative limit", limits: []int{-1, 4}, want: []string{"", "0123"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+			for i, limit := range tt.limits {
+				data, err := BufferRequestBody(c, limit)
+				if err != nil {
+					t.Fatalf("BufferRequestBody(%d): %v", limit, err)
+				}
+				if string(data) != tt.want[i] {
+					t.Errorf("BufferRequestBody(%d) = %q, want %q", limit, data, tt.want[i])
+				}
+			}
+
+			var read bytes.Buffer
+			if _, err := read.ReadFrom(c.Request.Body); err != nil {
+				t.Fatal(err)
+			}
+			if read.String() != body {
+				t.Errorf("the handlers read %q, want the whole body %q", read.String(), body)
+			}
+		})
+	}
+}
+
+func TestBufferRequestBodyNoBody(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if data, err := BufferRequestBody(c, 16); data != nil || err != nil {
+		t.Errorf("BufferRequestBody() = %q, %v, want nil, nil", data, err)
+	}
+}
+
+func TestCaptureRequestBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		body      string
+		want      string
+		truncated bool
+	}{
+		{name: "default limit", body: "short body", want: "short body"},
+		{name: "negative limit", limit: -1, body: "short body", want: "short body"},
+		{name: "truncated", limit: 5, body: "truncated body", want: "trunc", truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{CaptureRequestBody: true, RequestBodyLimit: tt.limit})
+			var read string
+			r.POST("/", func(c *gin.Context) {
+				var buf bytes.Buffer
+				_, _ = buf.ReadFrom(c.Request.Body)
+				read = buf.String()
+				panic("boom")
+			})
+
+			serveRequest(r, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+			if read != tt.body {
+				t.Errorf("the handler read %q, want %q", read, tt.body)
+			}
+			event := onlyEvent(t, transport)
+			if event.Request == nil || event.Request.Data != tt.want {
+				t.Errorf("request = %+v, want data %q", event.Request, tt.want)
+			}
+			if got := event.Extra["request_body_truncated"] == true; got != tt.truncated {
+				t.Errorf("request_body_truncated = %t, want %t", got, tt.truncated)
+			}
+		})
+	}
+}
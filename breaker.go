@@ -0,0 +1,71 @@
+package sentrygin
+
+import (
+	"sync"
+	"time"
+)
+
+// FlushCircuitBreaker configures the circuit breaker of the blocking flushes of WaitForDelivery.
+// After Failures consecutive flushes have timed out, the events are sent asynchronously for Cooldown,
+// so an unreachable Sentry doesn't add the full Timeout to the latency of every panicking request.
+// Once the cooldown is over, the next flush is attempted again; if it times out as well, the breaker reopens.
+type FlushCircuitBreaker struct {
+	Failures int
+	Cooldown time.Duration
+}
+
+// flushBreaker is the state of a FlushCircuitBreaker, it's safe for concurrent use.
+type flushBreaker struct {
+	failures int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	timeouts  int
+	openUntil time.Time
+}
+
+// newFlushBreaker returns nil if cfg disables the breaker.
+func newFlushBreaker(cfg FlushCircuitBreaker) *flushBreaker {
+	if cfg.Failures <= 0 || cfg.Cooldown <= 0 {
+		return nil
+	}
+
+	return &flushBreaker{
+		failures: cfg.Failures,
+		cooldown: cfg.Cooldown,
+		now:      time.Now,
+	}
+}
+
+// allow reports whether a blocking flush should be attempted, a nil breaker always allows it.
+func (b *flushBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.now().Before(b.openUntil)
+}
+
+// record records the result of a flush, opening the breaker once too many consecutive flushes have timed out.
+func (b *flushBreaker) record(delivered bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if delivered {
+		b.timeouts = 0
+		return
+	}
+
+	b.timeouts++
+	if b.timeouts >= b.failures {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
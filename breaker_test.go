@@ -0,0 +1,85 @@
+package sentrygin
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlushBreaker(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newFlushBreaker(FlushCircuitBreaker{Failures: 2, Cooldown: time.Minute})
+	b.now = func() time.Time {
+		return now
+	}
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		delivered bool
+		allow     bool
+	}{
+		{name: "closed", allow: true},
+		{name: "first timeout", allow: true},
+		{name: "opened by the second timeout", allow: false},
+		{name: "still open", advance: 59 * time.Second, allow: false},
+		{name: "half-open once the cooldown is over", advance: time.Second, allow: true},
+		{name: "reopened by a timeout", allow: false},
+		{name: "half-open again", advance: time.Minute, delivered: true, allow: true},
+		{name: "closed by a delivery", allow: true},
+		{name: "a single timeout doesn't open it", allow: true},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		if got := b.allow(); got != step.allow {
+			t.Fatalf("%s: allow() = %t, want %t", step.name, got, step.allow)
+		}
+		if step.allow {
+			b.record(step.delivered)
+		}
+	}
+}
+
+func TestFlushBreakerDisabled(t *testing.T) {
+	tests := []FlushCircuitBreaker{
+		{},
+		{Failures: 3},
+		{Cooldown: time.Minute},
+		{Failures: -1, Cooldown: time.Minute},
+	}
+
+	for _, cfg := range tests {
+		b := newFlushBreaker(cfg)
+		if b != nil {
+			t.Errorf("newFlushBreaker(%+v) = %+v, want nil", cfg, b)
+		}
+		// A nil breaker always allows the flush.
+		b.record(false)
+		if !b.allow() {
+			t.Error("a nil breaker must allow the flush")
+		}
+	}
+}
+
+func TestFlushBreakerConcurrent(t *testing.T) {
+	b := newFlushBreaker(FlushCircuitBreaker{Failures: 10, Cooldown: time.Hour})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if b.allow() {
+					b.record(false)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if b.allow() {
+		t.Error("the breaker hasn't been opened by 80 concurrent timeouts")
+	}
+}
@@ -0,0 +1,35 @@
+package sentrygin
+
+import (
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Group returns a middleware starting a child span of the request transaction named name,
+// covering the rest of the handlers chain of a route group:
+//
+//	api := r.Group("/api", sentrygin.Group("api"))
+//	v2 := api.Group("/v2", sentrygin.Group("api.v2"))
+//
+// Nested groups produce nested spans. Requests that are not traced are passed through.
+func Group(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent := sentry.SpanFromContext(c.Request.Context())
+		if parent == nil {
+			c.Next()
+			return
+		}
+
+		span := parent.StartChild("gin.group")
+		span.Description = name
+		defer span.Finish()
+
+		r := c.Request
+		c.Request = r.WithContext(span.Context())
+		defer func() {
+			c.Request = c.Request.WithContext(r.Context())
+		}()
+
+		c.Next()
+	}
+}
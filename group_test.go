@@ -0,0 +1,49 @@
+package sentrygin
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	r, transport := newRouter(t, Options{})
+	api := r.Group("/api", Group("api"))
+	v2 := api.Group("/v2", Group("api.v2"))
+	v2.GET("/users", func(c *gin.Context) {})
+
+	serve(r, http.MethodGet, "/api/v2/users")
+
+	transaction := onlyTransaction(t, transport)
+	if len(transaction.Spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(transaction.Spans))
+	}
+	spans := make(map[string]int)
+	for i, span := range transaction.Spans {
+		if span.Op != "gin.group" {
+			t.Errorf("op = %q, want gin.group", span.Op)
+		}
+		spans[span.Description] = i
+	}
+	outer, inner := transaction.Spans[spans["api"]], transaction.Spans[spans["api.v2"]]
+	if outer.ParentSpanID != transaction.Contexts["trace"]["span_id"] {
+		t.Errorf("parent of the api span = %s, want the transaction", outer.ParentSpanID)
+	}
+	if inner.ParentSpanID != outer.SpanID {
+		t.Errorf("parent of the api.v2 span = %s, want the api span %s", inner.ParentSpanID, outer.SpanID)
+	}
+}
+
+func TestGroupUntraced(t *testing.T) {
+	r := gin.New()
+	called := false
+	r.Group("/api", Group("api")).GET("/users", func(c *gin.Context) {
+		called = true
+	})
+
+	serve(r, http.MethodGet, "/api/users")
+
+	if !called {
+		t.Error("the handler hasn't been called")
+	}
+}
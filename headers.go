@@ -0,0 +1,72 @@
+package sentrygin
+
+import (
+	"github.com/getsentry/sentry-go"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+const filteredValue = "[Filtered]"
+
+var defaultScrubHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+func canonicalHeaderKeys(keys []string) []string {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	canonical := make([]string, len(keys))
+	for i, k := range keys {
+		canonical[i] = textproto.CanonicalMIMEHeaderKey(k)
+	}
+
+	return canonical
+}
+
+// scrubRequest returns a shallow copy of r with the values of the given headers replaced with "[Filtered]".
+// r is returned as is if it doesn't contain any of the headers.
+func scrubRequest(r *http.Request, keys []string) *http.Request {
+	var header http.Header
+	for _, k := range keys {
+		if _, ok := r.Header[k]; !ok {
+			continue
+		}
+		if header == nil {
+			header = r.Header.Clone()
+		}
+		header[k] = []string{filteredValue}
+	}
+
+	if header == nil {
+		return r
+	}
+
+	scrubbed := new(http.Request)
+	*scrubbed = *r
+	scrubbed.Header = header
+
+	return scrubbed
+}
+
+// headersContext returns a context with the values of the given headers, nil if none of them is present.
+func headersContext(header http.Header, keys []string) sentry.Context {
+	var ctx sentry.Context
+	for _, k := range keys {
+		values, ok := header[k]
+		if !ok {
+			continue
+		}
+		if ctx == nil {
+			ctx = make(sentry.Context, len(keys))
+		}
+		ctx[k] = strings.Join(values, ",")
+	}
+
+	return ctx
+}
@@ -0,0 +1,48 @@
+package sentrygin
+
+import (
+	"context"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// httpNextKey is the request context key of the http.Handler wrapped by NewHTTP.
+type httpNextKey struct{}
+
+// NewHTTP returns a middleware for net/http handlers with the same semantics as New,
+// e.g. for http.Handlers mounted alongside a gin.Engine. There's no route template available,
+// so transactions are named after the raw request path (NormalizePath still applies,
+// UnmatchedTransactionName doesn't).
+//
+// The wrapped handlers are served by a bare gin.Engine created once per call, so the middleware, including
+// panic recovery, is shared with the gin path and the callbacks of Options receive a *gin.Context as usual.
+// The engine trusts no proxy, so the client IP (see SetClientIP) is the remote address of the connection,
+// X-Forwarded-For and X-Real-IP headers are ignored as any client could spoof them.
+func NewHTTP(opts Options) func(http.Handler) http.Handler {
+	warnIfUninitialized(opts)
+	warnIfNotProfiling(opts, sentry.CurrentHub().Client())
+
+	h := newHandler(opts, &handlerState{})
+	h.pathNames = true
+
+	engine := gin.New()
+	// SetTrustedProxies only fails for invalid proxies, which nil can't contain.
+	_ = engine.SetTrustedProxies(nil)
+	engine.Use(h.handle)
+	engine.NoRoute(func(c *gin.Context) {
+		// Gin presets the status of unmatched requests to 404, it has to be reset,
+		// so the status written implicitly by next is 200 as with plain net/http.
+		c.Status(http.StatusOK)
+		c.Request.Context().Value(httpNextKey{}).(http.Handler).ServeHTTP(c.Writer, c.Request)
+		// Gin writes its 404 page once the handlers return if the status is still 404 and nothing
+		// has been written, e.g. after a bare w.WriteHeader(http.StatusNotFound) of next.
+		c.Writer.WriteHeaderNow()
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			engine.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), httpNextKey{}, next)))
+		})
+	}
+}
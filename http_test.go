@@ -0,0 +1,85 @@
+package sentrygin
+
+import (
+	"bytes"
+	"github.com/getsentry/sentry-go"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewHTTP(t *testing.T) {
+	transport := bindCurrentHub(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := NewHTTP(Options{SetClientIP: true})(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rec := serveRequest(handler, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("response = %d %q, want 200 ok", rec.Code, rec.Body.String())
+	}
+	transaction := onlyTransaction(t, transport)
+	if transaction.Transaction != "GET /users/1" {
+		t.Errorf("transaction = %q, want GET /users/1", transaction.Transaction)
+	}
+	if got := transaction.User.IPAddress; got != "10.0.0.1" {
+		t.Errorf("client IP = %q, want the remote address rather than the spoofable X-Forwarded-For", got)
+	}
+
+	transport.Reset()
+	serve(handler, http.MethodGet, "/panic")
+
+	if event := onlyEvent(t, transport); event.Message != "boom" {
+		t.Errorf("message = %q, want the panic to be reported", event.Message)
+	}
+	if transaction := onlyTransaction(t, transport); transaction.Contexts["trace"]["status"] != sentry.HTTPtoSpanStatus(http.StatusInternalServerError) {
+		t.Errorf("status = %v, want internal error", transaction.Contexts["trace"]["status"])
+	}
+}
+
+func TestNewHTTPNotFound(t *testing.T) {
+	transport := bindCurrentHub(t)
+	handler := NewHTTP(Options{UnmatchedTransactionName: "<unmatched>"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	for _, target := range []string{"/users/1", "/users/2"} {
+		transport.Reset()
+		rec := serve(handler, http.MethodGet, target)
+
+		if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+			t.Errorf("%s: response = %d %q, want 404 without a body", target, rec.Code, rec.Body.String())
+		}
+		if got, want := onlyTransaction(t, transport).Transaction, "GET "+target; got != want {
+			t.Errorf("transaction = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestNewHTTPWarnIfUninitialized(t *testing.T) {
+	unbindCurrentHub(t)
+	var logs bytes.Buffer
+	handler := NewHTTP(Options{WarnIfUninitialized: true, Logger: log.New(&logs, "", 0)})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	rec := serve(handler, http.MethodGet, "/users/1")
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("response = %d %q, want 200 ok", rec.Code, rec.Body.String())
+	}
+	if got := strings.Count(logs.String(), "no client is bound"); got != 1 {
+		t.Errorf("the warning has been logged %d times, want 1:\n%s", got, logs.String())
+	}
+}
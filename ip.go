@@ -0,0 +1,22 @@
+package sentrygin
+
+import "net"
+
+// TruncateIP anonymizes an IP address by zeroing its last octet (IPv4) or hextet (IPv6),
+// e.g. 203.0.113.42 becomes 203.0.113.0. It's meant to be used as AnonymizeIP.
+// It returns an empty string if ip can't be parsed, so unexpected values aren't stored as is.
+func TruncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		v4[3] = 0
+		return v4.String()
+	}
+
+	parsed[14], parsed[15] = 0, 0
+
+	return parsed.String()
+}
@@ -0,0 +1,25 @@
+package sentrygin
+
+import "testing"
+
+func TestTruncateIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "203.0.113.42", want: "203.0.113.0"},
+		{ip: "10.0.0.0", want: "10.0.0.0"},
+		{ip: "::ffff:203.0.113.42", want: "203.0.113.0"},
+		{ip: "2001:db8:85a3::8a2e:370:7334", want: "2001:db8:85a3::8a2e:370:0"},
+		{ip: "::1", want: "::"},
+		{ip: "", want: ""},
+		{ip: "not an ip", want: ""},
+		{ip: "203.0.113.42:8080", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := TruncateIP(tt.ip); got != tt.want {
+			t.Errorf("TruncateIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
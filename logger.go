@@ -0,0 +1,44 @@
+package sentrygin
+
+import (
+	"context"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+)
+
+// LoggerKey is the gin.Context key under which the request-scoped *slog.Logger is stored when InjectLogger is set.
+const LoggerKey = "sentrygin.logger"
+
+type loggerKey struct{}
+
+// withLogger stores the logger derived from base on both c and ctx, the logger carries the trace_id
+// and span_id attributes of span, if any, so the log lines of the request can be correlated with its trace.
+func withLogger(c *gin.Context, ctx context.Context, base *slog.Logger, span *sentry.Span) context.Context {
+	logger := base
+	if span != nil {
+		logger = base.With(
+			slog.String("trace_id", span.TraceID.String()),
+			slog.String("span_id", span.SpanID.String()),
+		)
+	}
+
+	c.Set(LoggerKey, logger)
+
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger injected by the middleware (see InjectLogger), looking it up
+// in c and then in the request context. It returns slog.Default() if there's none.
+func LoggerFromContext(c *gin.Context) *slog.Logger {
+	if logger, ok := c.Value(LoggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	if c.Request != nil {
+		if logger, ok := c.Request.Context().Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+
+	return slog.Default()
+}
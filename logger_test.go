@@ -0,0 +1,52 @@
+package sentrygin
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestInjectLogger(t *testing.T) {
+	var logs bytes.Buffer
+	r, transport := newRouter(t, Options{InjectLogger: slog.New(slog.NewJSONHandler(&logs, nil))})
+	r.GET("/", func(c *gin.Context) {
+		LoggerFromContext(c).Info("from gin.Context")
+		if logger, ok := c.Request.Context().Value(loggerKey{}).(*slog.Logger); ok {
+			logger.Info("from the request context")
+		} else {
+			t.Error("no logger on the request context")
+		}
+	})
+
+	serve(r, http.MethodGet, "/")
+
+	trace := onlyTransaction(t, transport).Contexts["trace"]
+	decoder := json.NewDecoder(&logs)
+	for i := 0; i < 2; i++ {
+		var line map[string]interface{}
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("log line %d: %v", i, err)
+		}
+		if got, want := line["trace_id"], trace["trace_id"].(sentry.TraceID).String(); got != want {
+			t.Errorf("trace_id of %q = %v, want %s", line["msg"], got, want)
+		}
+		if got, want := line["span_id"], trace["span_id"].(sentry.SpanID).String(); got != want {
+			t.Errorf("span_id of %q = %v, want %s", line["msg"], got, want)
+		}
+	}
+}
+
+func TestLoggerFromContextDefault(t *testing.T) {
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		if got := LoggerFromContext(c); got != slog.Default() {
+			t.Errorf("logger = %v, want slog.Default()", got)
+		}
+	})
+
+	serve(r, http.MethodGet, "/")
+}
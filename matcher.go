@@ -0,0 +1,47 @@
+package sentrygin
+
+import "strings"
+
+// pathMatcher matches request paths against a set of exact paths and prefixes.
+// A nil *pathMatcher matches nothing.
+type pathMatcher struct {
+	exact    map[string]struct{}
+	prefixes []string
+}
+
+func newPathMatcher(patterns []string) *pathMatcher {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	m := &pathMatcher{
+		exact: make(map[string]struct{}, len(patterns)),
+	}
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") {
+			m.prefixes = append(m.prefixes, strings.TrimSuffix(p, "*"))
+			continue
+		}
+		m.exact[p] = struct{}{}
+	}
+
+	return m
+}
+
+func (m *pathMatcher) match(path string) bool {
+	if m == nil || path == "" {
+		return false
+	}
+
+	if _, ok := m.exact[path]; ok {
+		return true
+	}
+
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+package sentrygin
+
+import "strings"
+
+// normalizeIDs replaces numeric path segments with ":id" and UUID path segments with ":uuid".
+func normalizeIDs(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case isNumeric(segment):
+			segments[i] = ":id"
+		case isUUID(segment):
+			segments[i] = ":uuid"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isUUID reports whether s is a UUID in the canonical 8-4-4-4-12 form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(s[i]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func isHexDigit(b byte) bool {
+	return '0' <= b && b <= '9' || 'a' <= b && b <= 'f' || 'A' <= b && b <= 'F'
+}
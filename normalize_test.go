@@ -0,0 +1,28 @@
+package sentrygin
+
+import "testing"
+
+func TestNormalizeIDs(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/", want: "/"},
+		{path: "/users", want: "/users"},
+		{path: "/users/42", want: "/users/:id"},
+		{path: "/users/42/orders/7", want: "/users/:id/orders/:id"},
+		{path: "/files/3f2c1b9e-8d4a-4c6b-9e1f-0a2b3c4d5e6f", want: "/files/:uuid"},
+		{path: "/files/3F2C1B9E-8D4A-4C6B-9E1F-0A2B3C4D5E6F/raw", want: "/files/:uuid/raw"},
+		{path: "/users/42/", want: "/users/:id/"},
+		{path: "/v2/users", want: "/v2/users"},
+		{path: "/users/42a", want: "/users/42a"},
+		{path: "/files/3f2c1b9e8d4a4c6b9e1f0a2b3c4d5e6f", want: "/files/3f2c1b9e8d4a4c6b9e1f0a2b3c4d5e6f"},
+		{path: "/files/3f2c1b9e-8d4a-4c6b-9e1f-0a2b3c4d5e6g", want: "/files/3f2c1b9e-8d4a-4c6b-9e1f-0a2b3c4d5e6g"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeIDs(tt.path); got != tt.want {
+			t.Errorf("normalizeIDs(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+module github.com/Kichiyaki/sentrygin/otelbridge
+
+go 1.21
+
+// The sentrygin requirement is the first revision providing Options.TraceContextFromRequest, it's what the users
+// of otelbridge get, as the replace directive below is ignored outside of this module. Releases of otelbridge
+// have to bump it to the tagged release of sentrygin they're tested with.
+require (
+	github.com/Kichiyaki/sentrygin v0.0.0-20261014045910-c714e0006057
+	github.com/getsentry/sentry-go v0.23.0
+	github.com/gin-gonic/gin v1.8.1
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/go-playground/locales v0.14.0 // indirect
+	github.com/go-playground/universal-translator v0.18.0 // indirect
+	github.com/go-playground/validator/v10 v10.11.1 // indirect
+	github.com/goccy/go-json v0.9.11 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/leodido/go-urn v1.2.1 // indirect
+	github.com/mattn/go-isatty v0.0.17 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/pelletier/go-toml/v2 v2.0.5 // indirect
+	github.com/ugorji/go/codec v1.2.7 // indirect
+	go.opentelemetry.io/otel v1.24.0 // indirect
+	golang.org/x/crypto v0.7.0 // indirect
+	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/sys v0.6.0 // indirect
+	golang.org/x/text v0.8.0 // indirect
+	google.golang.org/protobuf v1.29.1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
+
+// The local checkout is used during development, so changes of both modules can be tested together.
+replace github.com/Kichiyaki/sentrygin => ../
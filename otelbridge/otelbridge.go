@@ -0,0 +1,36 @@
+// Package otelbridge continues OpenTelemetry spans with the transactions of sentrygin, so the spans of both
+// systems share a trace ID. It's a separate module, so sentrygin doesn't depend on OpenTelemetry:
+//
+//	r.Use(otelgin.Middleware("users"))
+//	r.Use(sentrygin.New(sentrygin.Options{
+//		TraceContextFromRequest: otelbridge.SpanContextFromRequest,
+//	}))
+package otelbridge
+
+import (
+	"github.com/Kichiyaki/sentrygin"
+	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// SpanContextFromRequest returns the OpenTelemetry span of the request context, if any (e.g. started by
+// an OpenTelemetry middleware registered before sentrygin), see sentrygin.Options.TraceContextFromRequest.
+// The sampling decision of the span is adopted as well.
+func SpanContextFromRequest(r *http.Request) (sentrygin.SpanContext, bool) {
+	sc := trace.SpanContextFromContext(r.Context())
+	if !sc.IsValid() {
+		return sentrygin.SpanContext{}, false
+	}
+
+	sampled := sentry.SampledFalse
+	if sc.IsSampled() {
+		sampled = sentry.SampledTrue
+	}
+
+	return sentrygin.SpanContext{
+		TraceID: sentry.TraceID(sc.TraceID()),
+		SpanID:  sentry.SpanID(sc.SpanID()),
+		Sampled: sampled,
+	}, true
+}
@@ -0,0 +1,116 @@
+package otelbridge_test
+
+import (
+	"github.com/Kichiyaki/sentrygin"
+	"github.com/Kichiyaki/sentrygin/otelbridge"
+	"github.com/Kichiyaki/sentrygin/testtransport"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestSpanContextFromRequest(t *testing.T) {
+	otelSpan := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	tests := []struct {
+		name    string
+		span    trace.SpanContext
+		traceID string
+		parent  string
+	}{
+		{
+			name:    "span",
+			span:    otelSpan,
+			traceID: otelSpan.TraceID().String(),
+			parent:  otelSpan.SpanID().String(),
+		},
+		{name: "no span"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport, err := testtransport.NewHub(sentry.ClientOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := gin.New()
+			// stands in for an OpenTelemetry middleware
+			r.Use(func(c *gin.Context) {
+				if tt.span.IsValid() {
+					c.Request = c.Request.WithContext(trace.ContextWithSpanContext(c.Request.Context(), tt.span))
+				}
+			})
+			r.Use(sentrygin.NewWithClient(hub.Client(), sentrygin.Options{
+				TraceContextFromRequest: otelbridge.SpanContextFromRequest,
+			}))
+			var handlerSpan trace.SpanContext
+			r.GET("/users/:id", func(c *gin.Context) {
+				handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+			})
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+			if !handlerSpan.Equal(tt.span) {
+				t.Errorf("the OpenTelemetry span of the handler = %v, want %v", handlerSpan, tt.span)
+			}
+
+			transactions := transport.Transactions()
+			if len(transactions) != 1 {
+				t.Fatalf("%d transactions have been reported, want 1", len(transactions))
+			}
+			traceCtx := transactions[0].Contexts["trace"]
+			gotTraceID := traceCtx["trace_id"].(sentry.TraceID).String()
+			if tt.traceID != "" && gotTraceID != tt.traceID {
+				t.Errorf("trace ID = %s, want %s", gotTraceID, tt.traceID)
+			}
+			if tt.traceID == "" && gotTraceID == otelSpan.TraceID().String() {
+				t.Error("a trace has been continued without an OpenTelemetry span")
+			}
+			parent, ok := traceCtx["parent_span_id"].(sentry.SpanID)
+			if tt.parent == "" && ok {
+				t.Errorf("parent span ID = %s, want none", parent)
+			}
+			if tt.parent != "" && parent.String() != tt.parent {
+				t.Errorf("parent span ID = %s, want %s", parent, tt.parent)
+			}
+		})
+	}
+}
+
+func TestSpanContextFromRequestSampled(t *testing.T) {
+	for _, tt := range []struct {
+		flags trace.TraceFlags
+		want  sentry.Sampled
+	}{
+		{flags: trace.FlagsSampled, want: sentry.SampledTrue},
+		{flags: 0, want: sentry.SampledFalse},
+	} {
+		span := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{0x01},
+			SpanID:     trace.SpanID{0x01},
+			TraceFlags: tt.flags,
+		})
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req = req.WithContext(trace.ContextWithSpanContext(req.Context(), span))
+
+		sc, ok := otelbridge.SpanContextFromRequest(req)
+		if !ok {
+			t.Fatalf("flags %s: no span context has been returned", tt.flags)
+		}
+		if sc.Sampled != tt.want {
+			t.Errorf("flags %s: sampled = %v, want %v", tt.flags, sc.Sampled, tt.want)
+		}
+	}
+}
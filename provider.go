@@ -0,0 +1,121 @@
+package sentrygin
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// maxProviderHandlers is the maximum number of handlers NewWithProvider caches, one per distinct Options.
+const maxProviderHandlers = 16
+
+// handlerCache caches the handlers built by NewWithProvider, so the options returned by the provider are only
+// turned into a handler (matchers, header sets, ...) the first time they're seen.
+type handlerCache struct {
+	mu      sync.RWMutex
+	entries []handlerCacheEntry
+}
+
+// optionsPool pools the options returned by the providers of NewWithProvider, they're compared through
+// reflection, which would otherwise allocate them for every request.
+var optionsPool = sync.Pool{
+	New: func() interface{} {
+		return new(Options)
+	},
+}
+
+type handlerCacheEntry struct {
+	opts    Options
+	handler *handler
+}
+
+// get returns the handler built for the same options as opts, or nil if there's none.
+func (hc *handlerCache) get(opts *Options) *handler {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	for i := range hc.entries {
+		if sameOptions(&hc.entries[i].opts, opts) {
+			return hc.entries[i].handler
+		}
+	}
+
+	return nil
+}
+
+// add caches h as the handler of opts, unless the cache is full.
+func (hc *handlerCache) add(opts Options, h *handler) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if len(hc.entries) < maxProviderHandlers {
+		hc.entries = append(hc.entries, handlerCacheEntry{opts: opts, handler: h})
+	}
+}
+
+// sameOptions reports whether a and b configure the same handler. Funcs are the same if they're the same
+// func value, i.e. the same function or the same closure, pointers if they point to the same value,
+// maps and slices if they're deeply equal.
+func sameOptions(a, b *Options) bool {
+	// Options copied from one another, e.g. returned by a constant provider, are identical in memory,
+	// which is much cheaper to check than comparing their fields one by one.
+	size := unsafe.Sizeof(*a)
+	if bytes.Equal(unsafe.Slice((*byte)(unsafe.Pointer(a)), size), unsafe.Slice((*byte)(unsafe.Pointer(b)), size)) {
+		return true
+	}
+
+	return sameValue(reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem())
+}
+
+// sameValue reports whether the addressable values a and b of the same type are the same, see sameOptions.
+// The common cases are compared without allocating, as the options are compared for every request.
+func sameValue(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Func:
+		return funcPointer(a) == funcPointer(b)
+	case reflect.Ptr, reflect.Chan:
+		return a.Pointer() == b.Pointer()
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !sameValue(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Map:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		if a.Pointer() == b.Pointer() {
+			return true
+		}
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Elem().Type() != b.Elem().Type() || !a.Elem().Type().Comparable() {
+			return false
+		}
+		return a.Interface() == b.Interface()
+	default:
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+}
+
+// funcPointer returns the address of the func value stored in the addressable value v. Unlike v.Pointer(),
+// the address of the code of the func, it tells apart closures of the same function capturing distinct variables.
+func funcPointer(v reflect.Value) unsafe.Pointer {
+	return *(*unsafe.Pointer)(v.Addr().UnsafePointer())
+}
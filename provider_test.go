@@ -0,0 +1,182 @@
+package sentrygin
+
+import (
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func namedTransaction(c *gin.Context) string {
+	return "named"
+}
+
+// populatedOptions returns options whose fields are all set to non-zero values.
+func populatedOptions(t *testing.T) Options {
+	t.Helper()
+
+	var opts Options
+	populate(t, reflect.ValueOf(&opts).Elem())
+
+	return opts
+}
+
+func populate(t *testing.T, v reflect.Value) {
+	t.Helper()
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Func:
+		v.Set(reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+			results := make([]reflect.Value, v.Type().NumOut())
+			for i := range results {
+				results[i] = reflect.Zero(v.Type().Out(i))
+			}
+			return results
+		}))
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		key, value := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		populate(t, key)
+		populate(t, value)
+		m.SetMapIndex(key, value)
+		v.Set(m)
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 1, 1)
+		populate(t, s.Index(0))
+		v.Set(s)
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(hubKey{}))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			populate(t, v.Field(i))
+		}
+	default:
+		t.Fatalf("can't populate a %s", v.Type())
+	}
+}
+
+func TestSameOptions(t *testing.T) {
+	populated := populatedOptions(t)
+	closure := func(prefix string) func(c *gin.Context) string {
+		return func(c *gin.Context) string {
+			return prefix
+		}
+	}
+	admin := closure("admin")
+
+	tests := []struct {
+		name string
+		a, b Options
+		want bool
+	}{
+		{name: "zero", want: true},
+		{name: "populated", a: populated, b: populated, want: true},
+		{name: "bool", a: Options{Repanic: true}, want: false},
+		{name: "duration", a: Options{Timeout: time.Second}, b: Options{Timeout: time.Second}, want: true},
+		{name: "function", a: Options{TransactionName: namedTransaction}, b: Options{TransactionName: namedTransaction}, want: true},
+		{name: "same closure", a: Options{TransactionName: admin}, b: Options{TransactionName: admin}, want: true},
+		{name: "distinct closures", a: Options{TransactionName: closure("admin")}, b: Options{TransactionName: closure("users")}, want: false},
+		{
+			name: "equal maps",
+			a:    Options{DefaultTags: map[string]string{"area": "admin"}},
+			b:    Options{DefaultTags: map[string]string{"area": "admin"}},
+			want: true,
+		},
+		{
+			name: "distinct maps",
+			a:    Options{DefaultTags: map[string]string{"area": "admin"}},
+			b:    Options{DefaultTags: map[string]string{"area": "users"}},
+			want: false,
+		},
+		{name: "nil and empty slices", a: Options{ScrubHeaders: []string{}}, want: false},
+		{name: "pointers", a: Options{Logger: populated.Logger}, b: Options{Logger: populatedOptions(t).Logger}, want: false},
+		{name: "interfaces", a: Options{ContextHubKey: hubKey{}}, b: Options{ContextHubKey: hubKey{}}, want: true},
+		{name: "distinct interfaces", a: Options{ContextHubKey: hubKey{}}, b: Options{ContextHubKey: "hub"}, want: false},
+		{
+			name: "event processors",
+			a:    Options{EventProcessors: []sentry.EventProcessor{populated.EventProcessors[0]}},
+			b:    Options{EventProcessors: []sentry.EventProcessor{populated.EventProcessors[0]}},
+			want: false,
+		},
+		{
+			name: "struct",
+			a:    Options{FlushCircuitBreaker: FlushCircuitBreaker{Failures: 3, Cooldown: time.Second}},
+			b:    Options{FlushCircuitBreaker: FlushCircuitBreaker{Failures: 3, Cooldown: time.Minute}},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameOptions(&tt.a, &tt.b); got != tt.want {
+				t.Errorf("sameOptions(a, b) = %t, want %t", got, tt.want)
+			}
+			if got := sameOptions(&tt.b, &tt.a); got != tt.want {
+				t.Errorf("sameOptions(b, a) = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerCache(t *testing.T) {
+	var cache handlerCache
+	state := &handlerState{}
+	admin := Options{WaitForDelivery: true, DefaultTags: map[string]string{"area": "admin"}}
+	h := newHandler(admin, state)
+	cache.add(admin, h)
+
+	if got := cache.get(&Options{WaitForDelivery: true, DefaultTags: map[string]string{"area": "admin"}}); got != h {
+		t.Error("the handler of the same options hasn't been returned")
+	}
+	if got := cache.get(&Options{}); got != nil {
+		t.Error("a handler has been returned for distinct options")
+	}
+
+	for i := 1; i < maxProviderHandlers+1; i++ {
+		cache.add(Options{Timeout: time.Duration(i)}, newHandler(Options{}, state))
+	}
+	if got := len(cache.entries); got != maxProviderHandlers {
+		t.Errorf("%d handlers have been cached, want at most %d", got, maxProviderHandlers)
+	}
+}
+
+func BenchmarkSameOptions(b *testing.B) {
+	opts := Options{
+		WaitForDelivery: true,
+		TransactionName: namedTransaction,
+		DefaultTags:     map[string]string{"area": "admin"},
+		IgnorePaths:     []string{"/healthz"},
+	}
+
+	for name, other := range map[string]Options{
+		"copy": opts,
+		"equal": {
+			WaitForDelivery: true,
+			TransactionName: namedTransaction,
+			DefaultTags:     map[string]string{"area": "admin"},
+			IgnorePaths:     []string{"/healthz"},
+		},
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if !sameOptions(&opts, &other) {
+					b.Fatal("the options differ")
+				}
+			}
+		})
+	}
+}
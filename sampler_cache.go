@@ -0,0 +1,68 @@
+package sentrygin
+
+import (
+	"container/list"
+	"sync"
+)
+
+type samplerCacheKey struct {
+	method string
+	route  string
+}
+
+type samplerDecision struct {
+	key  samplerCacheKey
+	rate float64
+	ok   bool
+}
+
+// samplerCache is a concurrency-safe LRU cache of TransactionSampler decisions.
+type samplerCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[samplerCacheKey]*list.Element
+}
+
+func newSamplerCache(size int) *samplerCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &samplerCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[samplerCacheKey]*list.Element, size),
+	}
+}
+
+func (sc *samplerCache) get(key samplerCacheKey) (samplerDecision, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	el, ok := sc.items[key]
+	if !ok {
+		return samplerDecision{}, false
+	}
+	sc.ll.MoveToFront(el)
+
+	return el.Value.(samplerDecision), true
+}
+
+func (sc *samplerCache) add(decision samplerDecision) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if el, ok := sc.items[decision.key]; ok {
+		el.Value = decision
+		sc.ll.MoveToFront(el)
+		return
+	}
+
+	sc.items[decision.key] = sc.ll.PushFront(decision)
+	if sc.ll.Len() > sc.size {
+		oldest := sc.ll.Back()
+		sc.ll.Remove(oldest)
+		delete(sc.items, oldest.Value.(samplerDecision).key)
+	}
+}
@@ -0,0 +1,64 @@
+package sentrygin
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSamplerCache(t *testing.T) {
+	if cache := newSamplerCache(0); cache != nil {
+		t.Fatal("a cache of size 0 must be disabled")
+	}
+
+	cache := newSamplerCache(2)
+	users := samplerCacheKey{method: "GET", route: "/users"}
+	orders := samplerCacheKey{method: "GET", route: "/orders"}
+	items := samplerCacheKey{method: "GET", route: "/items"}
+
+	cache.add(samplerDecision{key: users, rate: 0.1, ok: true})
+	cache.add(samplerDecision{key: orders, rate: 0.2, ok: true})
+	if decision, ok := cache.get(users); !ok || decision.rate != 0.1 {
+		t.Errorf("get(users) = %+v, %t, want rate 0.1", decision, ok)
+	}
+
+	// orders is now the least recently used decision, it's evicted first.
+	cache.add(samplerDecision{key: items, rate: 0.3, ok: true})
+	if _, ok := cache.get(orders); ok {
+		t.Error("the least recently used decision hasn't been evicted")
+	}
+	if _, ok := cache.get(users); !ok {
+		t.Error("a recently used decision has been evicted")
+	}
+
+	cache.add(samplerDecision{key: items, rate: 0.5, ok: false})
+	if decision, ok := cache.get(items); !ok || decision.rate != 0.5 || decision.ok {
+		t.Errorf("get(items) = %+v, %t, want the updated decision", decision, ok)
+	}
+	if got := cache.ll.Len(); got != 2 {
+		t.Errorf("cache holds %d decisions, want 2", got)
+	}
+}
+
+func TestSamplerCacheConcurrent(t *testing.T) {
+	cache := newSamplerCache(8)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := samplerCacheKey{method: "GET", route: "/" + strconv.Itoa((i+j)%16)}
+				if _, ok := cache.get(key); !ok {
+					cache.add(samplerDecision{key: key, rate: 1, ok: true})
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := cache.ll.Len(); got > 8 || got != len(cache.items) {
+		t.Errorf("cache holds %d decisions and %d items, want at most 8 of both", got, len(cache.items))
+	}
+}
@@ -2,12 +2,62 @@ package sentrygin
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/getsentry/sentry-go"
 	"github.com/gin-gonic/gin"
+	"log"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// HubKey is the gin.Context key under which the request-scoped *sentry.Hub is stored.
+const HubKey = "sentrygin.hub"
+
+// TraceIDKey is the gin.Context key under which the trace ID is stored when SetTraceIDHeader is set.
+const TraceIDKey = "sentry_trace_id"
+
+// panicReportedKey is the gin.Context key marking requests whose panic has been reported.
+const panicReportedKey = "sentrygin.panic_reported"
+
+// handledKey is the gin.Context key marking requests handled by the middleware, see DetectDoubleRegistration.
+const handledKey = "sentrygin.handled"
+
+// repanicDisabledKey is the gin.Context key marking requests whose panics mustn't be propagated, see DisableRepanic.
+const repanicDisabledKey = "sentrygin.repanic_disabled"
+
+// forceWaitKey is the gin.Context key marking requests whose panic events have to be waited for,
+// see ForceWaitForDelivery.
+const forceWaitKey = "sentrygin.force_wait_for_delivery"
+
+// breadcrumbCountKey is the gin.Context key under which the number of breadcrumbs added by the middleware is stored.
+const breadcrumbCountKey = "sentrygin.breadcrumb_count"
+
+// maxPromotedKeys is the maximum number of PromoteContextKeys promoted to tags.
+const maxPromotedKeys = 20
+
+// maxTagValueLength is the maximum length of tag values accepted by Sentry.
+const maxTagValueLength = 200
+
+// maxGoroutineDumpSize is the maximum size of the dump of AttachGoroutineDump.
+const maxGoroutineDumpSize = 64 << 10
+
+// maxNameHeaderLength is the maximum length of a TransactionNameHeader value, longer values are ignored.
+const maxNameHeaderLength = 200
+
+var defaultStaticExtensions = []string{
+	".js", ".mjs", ".map", ".css",
+	".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg", ".ico",
+	".woff", ".woff2", ".ttf", ".otf", ".eot",
+}
+
 // Options configure a Handler.
 type Options struct {
 	// Repanic configures whether Sentry should repanic after recovery, in most cases it should be set to true,
@@ -31,64 +81,1898 @@ type Options struct {
 	// If the timeout is reached, the current goroutine is no longer blocked
 	// waiting, but the delivery is not canceled.
 	Timeout time.Duration
+	// IgnorePaths lists requests for which no transaction is started, e.g. health checks or metrics endpoints.
+	// Panics are still recovered and reported for those requests.
+	//
+	// Each entry is matched against both the raw request path (c.Request.URL.Path)
+	// and the matched route template (c.FullPath()). An entry ending with "*" matches
+	// every path with the given prefix (e.g. "/debug/*"), any other entry has to match exactly.
+	IgnorePaths []string
+	// CaptureErrors configures whether errors attached to the gin.Context with c.Error
+	// should be reported to Sentry once the handlers chain returns.
+	CaptureErrors bool
+	// ReportErrorTypes is a bit mask of gin.ErrorType that should be reported when CaptureErrors is true.
+	// Defaults to gin.ErrorTypePrivate, which is the type assigned by c.Error.
+	ReportErrorTypes gin.ErrorType
+	// SetTraceHeadersOnResponse configures whether the sentry-trace and baggage headers
+	// should be set on the response, so a frontend SDK can link its spans to the transaction.
+	//
+	// The headers are set before the handlers chain is invoked, handlers are free to overwrite them.
+	SetTraceHeadersOnResponse bool
+	// TransactionName, if set, is used to compute the transaction name instead of the default
+	// "<method> <route>" naming, an empty return value falls back to the default.
+	//
+	// It is called before the handlers chain is invoked, c.FullPath() is already available,
+	// but values set by subsequent handlers are not.
+	TransactionName func(c *gin.Context) string
+	// CaptureRequestBody configures whether the request body should be buffered up front
+	// and attached to Sentry events, so the failing payload can be inspected.
+	// Bodies of multipart and binary streams are never captured.
+	CaptureRequestBody bool
+	// RequestBodyLimit is the maximum number of bytes of the request body attached to events.
+	// Defaults to 4096, which is used for negative values as well.
+	// Longer bodies are truncated, which is marked in the request_body_truncated extra.
+	//
+	// Note that the SDK doesn't send bodies longer than 10KiB, regardless of this value.
+	RequestBodyLimit int
+	// DefaultTags are set on the scope of every request, e.g. region or cluster the service runs in.
+	DefaultTags map[string]string
+	// KeepURLQuery configures whether the query string is kept in the http.url span data.
+	// It is stripped by default, as it often carries sensitive values such as tokens.
+	KeepURLQuery bool
+	// TransactionSampler, if set, makes a per-request sampling decision, e.g. to sample
+	// every /checkout request, but only a fraction of /feed requests.
+	//
+	// When it returns true, the returned sample rate takes precedence over both
+	// the sampling decision inherited from an incoming sentry-trace header and
+	// the TracesSampler/TracesSampleRate configured in the SDK. When it returns false,
+	// the decision is left to the SDK. EnableTracing still has to be set in the SDK options.
+	TransactionSampler func(c *gin.Context) (float64, bool)
+	// ScrubHeaders lists request headers (case-insensitive) whose values are replaced with "[Filtered]"
+	// in the request attached to Sentry events. The request seen by the handlers is left untouched.
+	//
+	// Defaults to Authorization, Cookie, Set-Cookie and X-Api-Key, set it to an empty non-nil slice
+	// to disable scrubbing.
+	ScrubHeaders []string
+	// ReuseHub configures whether, in case there's no hub on the request context, a scope
+	// should be pushed onto sentry.CurrentHub() for the duration of the request instead of cloning it.
+	// This avoids allocating a new hub for every request, at the cost of isolation.
+	//
+	// The scope stack of a hub is not meant to be shared between goroutines, concurrent requests
+	// push and pop scopes of the same hub, so data set on the scope of one request may end up
+	// on the events of another. Only enable it if requests are not handled concurrently,
+	// or if such mixing of data is acceptable.
+	ReuseHub bool
+	// BeforeCapture, if set, is called when a panic has been recovered, right before it's reported.
+	// It can be used to enrich hub.Scope() with tags, extra context or a fingerprint.
+	// A panic raised by BeforeCapture itself is discarded and the recovered panic is reported regardless.
+	BeforeCapture func(c *gin.Context, hub *sentry.Hub, recovered interface{})
+	// CaptureResponseErrors, if set, is called with the response status once the handlers chain returns,
+	// returning true reports the response to Sentry as a message event, e.g. to track handlers
+	// responding with 5xx without panicking. Responses of requests whose panic has already been
+	// reported are skipped.
+	CaptureResponseErrors func(status int) bool
+	// RecordDurationMeasurement configures whether the time spent handling the request, in milliseconds,
+	// should be recorded in the duration_ms span data. The transaction duration is derived from its
+	// timestamps, the data makes the value available for querying and charting (e.g. p95 latency)
+	// as a plain number. It is recorded for requests that panicked too.
+	RecordDurationMeasurement bool
+	// OnRecover, if set, is called with every recovered panic and the ID of the reported event (nil if the
+	// event hasn't been sent), e.g. to count panics in own metrics. It is called after the event has been
+	// captured and, if WaitForDelivery is true, flushed, but before repanicking.
+	OnRecover func(c *gin.Context, recovered interface{}, eventID *sentry.EventID)
+	// UserExtractor, if set, is called for every request to populate the user of the scope,
+	// e.g. from a value set with c.Set by an authentication middleware. A zero-value sentry.User is ignored.
+	//
+	// The extractor runs when the middleware is invoked, so the middleware has to be registered
+	// after the middleware authenticating the user, otherwise the extractor sees nothing.
+	UserExtractor func(c *gin.Context) sentry.User
+	// SetClientIP configures whether the IP address of the user should be set to c.ClientIP(),
+	// instead of the RemoteAddr of the request, which is the address of the load balancer when running behind one.
+	// c.ClientIP() respects the proxies trusted by the engine (see gin.Engine.SetTrustedProxies),
+	// so they have to be configured accordingly. An IP address returned by UserExtractor is left untouched.
+	SetClientIP bool
+	// FingerprintByRoute configures whether panics should be grouped by the matched route
+	// in addition to the default grouping, so panics of unrelated routes sharing the same
+	// stack trace (e.g. raised in a common wrapper) end up in distinct issues.
+	FingerprintByRoute bool
+	// Operation is the operation of the transaction. Defaults to "http.server".
+	Operation string
+	// AttachRouteParams configures whether the route parameters (c.Params) should be attached
+	// to the transaction as "params.<name>" span data.
+	AttachRouteParams bool
+	// ScrubParams lists route parameters whose values are replaced with "[Filtered]" when AttachRouteParams is true.
+	ScrubParams []string
+	// AddRequestBreadcrumb configures whether a breadcrumb describing the request (method and path)
+	// should be added at the start of every request, so it's visible in the timeline of the events.
+	// It counts towards the MaxBreadcrumbs limits of both the middleware and the SDK.
+	AddRequestBreadcrumb bool
+	// AddErrorBreadcrumbs configures whether a breadcrumb should be added for every error collected in c.Errors
+	// once the handlers chain returns, whether CaptureErrors reports it or not, so the errors are visible in the timeline
+	// of the events reported afterwards (e.g. by CaptureResponseErrors) and of the transaction.
+	// They count towards the MaxBreadcrumbs limits of both the middleware and the SDK.
+	AddErrorBreadcrumbs bool
+	// AbortWithInternalError configures whether the request should be aborted with 500 Internal Server Error
+	// after a panic has been reported, for setups without gin.Recovery. It only applies when Repanic is false.
+	//
+	// A recovered panic is handled in one of three ways:
+	//   - Repanic is true: the panic is propagated, e.g. to gin.Recovery which responds with 500,
+	//   - AbortWithInternalError is true: the request is aborted with 500, unless a response has already been written,
+	//   - both are false: the panic is swallowed and the response is left as the handler left it.
+	AbortWithInternalError bool
+	// LevelForPanic, if set, returns the level of the event reported for a recovered panic,
+	// e.g. to downgrade panics raised on purpose to sentry.LevelWarning. Panics are reported as sentry.LevelFatal otherwise.
+	LevelForPanic func(recovered interface{}) sentry.Level
+	// MinDuration, if set, drops transactions of requests handled faster than the given duration,
+	// so only slow requests are traced. Transactions of requests that panicked are never dropped.
+	//
+	// The sampling decision is made once the request has been handled, so the transaction is still
+	// started (and allocated) for every request, it's just not sent to Sentry.
+	MinDuration time.Duration
+	// HeaderContext lists request headers (case-insensitive), e.g. User-Agent or X-Client-Version,
+	// copied into the request_headers context of events. Missing headers are skipped,
+	// values of multi-valued headers are joined with a comma.
+	HeaderContext []string
+	// DropStacktrace configures whether errors reported with CaptureErrors that don't carry a stack trace
+	// (e.g. created with errors.New) should be reported without the stack trace the SDK captures at report time,
+	// which points to the middleware rather than to the origin of the error.
+	// Stack traces carried by errors themselves (e.g. github.com/pkg/errors) are reported regardless.
+	DropStacktrace bool
+	// EnableProfiling declares that the transactions of the middleware should be profiled. Profiling is driven
+	// by the SDK (sentry-go v0.23.0 or newer): sampled transactions are profiled according to the ProfilesSampleRate
+	// of sentry.ClientOptions and the profile is attached to the finished transaction. The option doesn't change
+	// that, the middleware only logs a warning to Logger if the ProfilesSampleRate of the client is 0.
+	EnableProfiling bool
+	// TagHandlerName configures whether the name of the last handler of the chain (c.HandlerName())
+	// should be set as the gin.handler tag of the transaction, e.g. to attribute transactions to code owners.
+	TagHandlerName bool
+	// CaptureContextErrors configures whether a message event should be reported when the request context
+	// is done once the handlers chain returns, e.g. because the client disconnected (reported as a warning)
+	// or a deadline has been exceeded (reported as an error). Nothing is reported if an event has already been
+	// captured for the request.
+	CaptureContextErrors bool
+	// Dist, if set, overrides the distribution of the events reported for requests handled by the middleware.
+	Dist string
+	// UnmatchedTransactionName, if set, is used in place of the raw path in the names of transactions
+	// of requests that matched no route, e.g. "<unmatched>" results in "GET <unmatched>". This collapses
+	// traffic of scanners probing random URLs into a single transaction.
+	UnmatchedTransactionName string
+	// ResponseHeaderContext lists response headers (case-insensitive), e.g. Cache-Control or X-Cache,
+	// copied into the response_headers context once the handlers chain returns, so they're visible on the events
+	// reported afterwards and on the transaction.
+	ResponseHeaderContext []string
+	// DisableTracing configures whether the middleware should only report panics and errors without
+	// starting transactions, e.g. when another tracing library is used. The hub is still set on the request
+	// context, so events reported by the handlers are correlated with the request.
+	DisableTracing bool
+	// BeforeSpanFinish, if set, is called right before the transaction is finished, when all request and response
+	// data is known, e.g. to rename the transaction or to drop transactions of 304 Not Modified responses.
+	// Returning false drops the transaction. It is called for requests that panicked too, with the status of
+	// the transaction set to sentry.SpanStatusInternalError.
+	BeforeSpanFinish func(c *gin.Context, span *sentry.Span) bool
+	// ContinueFromTraceparent configures whether a trace should be continued from the W3C traceparent header
+	// when the request carries no sentry-trace header, e.g. to link transactions to traces started by services
+	// instrumented with OpenTelemetry. Only the trace and parent span IDs are taken over, the sampled flag
+	// is ignored, so the sampling decision is made as for a new trace. A malformed header is ignored.
+	ContinueFromTraceparent bool
+	// RecordPayloadSizes configures whether the sizes of the request and response bodies should be recorded
+	// in the http.request_content_length and http.response_content_length span data. The request size is taken
+	// from the Content-Length header and is omitted when unknown.
+	RecordPayloadSizes bool
+	// ContextHubKey, if set, is an additional key under which the hub is stored in the request context,
+	// so other middlewares can find it deterministically with ctx.Value(ContextHubKey). It supplements,
+	// not replaces, the key used by the SDK (see sentry.GetHubFromContext).
+	ContextHubKey interface{}
+	// SkipRecover reports whether a recovered panic should not be reported. Such panics are always
+	// propagated, regardless of Repanic, to preserve the semantics of values like http.ErrAbortHandler,
+	// which net/http uses to abort a response without logging. Defaults to skipping http.ErrAbortHandler.
+	SkipRecover func(recovered interface{}) bool
+	// ServerName, if set, overrides the server name of the events (including transactions) reported
+	// for requests handled by the middleware, e.g. to report a logical service name instead of the pod hostname.
+	ServerName string
+	// TagMethodNotAllowed configures whether transactions of requests rejected with 405 Method Not Allowed
+	// (see gin.Engine.HandleMethodNotAllowed) should be tagged with http.method_not_allowed=true, to spot
+	// misbehaving clients. Their status is invalid_argument, as for any other 4xx response.
+	TagMethodNotAllowed bool
+	// ExtraContext, if set, is called for every request and the returned map is attached to the events
+	// as a context named ExtraContextName, e.g. with a tenant ID set with c.Set by an earlier middleware.
+	// A panic raised by ExtraContext is discarded and doesn't affect the request.
+	ExtraContext func(c *gin.Context) map[string]interface{}
+	// ExtraContextName is the name of the context populated by ExtraContext. Defaults to "request_meta".
+	ExtraContextName string
+	// CaptureQueryParams configures whether the query parameters should be recorded in the http.query span data,
+	// repeated parameters keep all their values in order.
+	CaptureQueryParams bool
+	// ScrubQueryParams lists query parameters (case-insensitive) whose values are replaced with "[Filtered]"
+	// in the http.query span data, e.g. token or signature.
+	ScrubQueryParams []string
+	// WarnIfUninitialized configures whether New should log a warning if no client is bound to sentry.CurrentHub(),
+	// which usually means that sentry.Init hasn't been called yet and events are silently dropped.
+	WarnIfUninitialized bool
+	// Logger is used to log the warnings of WarnIfUninitialized and DetectDoubleRegistration.
+	// Defaults to the standard logger.
+	Logger *log.Logger
+	// SamplerCacheSize, if greater than 0, is the number of TransactionSampler decisions cached by method and route template,
+	// so an expensive sampler is called once per route rather than for every request. Requests that matched no route
+	// are never cached. The sampler must not depend on anything but the method and the route when caching is enabled.
+	SamplerCacheSize int
+	// TagGinMode configures whether the mode gin runs in (see gin.Mode) should be set as the gin.mode tag.
+	// The mode is process-global, it's read once when the middleware is created.
+	TagGinMode bool
+	// EventProcessors are added to the scope of every request, so they only apply to events reported
+	// for requests handled by the middleware. They run in order, after the processors of the Dist and
+	// ServerName options, and can drop an event by returning nil.
+	EventProcessors []sentry.EventProcessor
+	// RespectContextDeadline configures whether the timeout for the delivery of panic events should be capped
+	// at the deadline of the request context, e.g. the remaining time of a serverless invocation, so the flush
+	// doesn't outlive it. Only relevant when WaitForDelivery is true.
+	RespectContextDeadline bool
+	// SetTraceIDHeader, if set, is the name of a response header (e.g. "X-Trace-Id") the trace ID of the transaction
+	// is written to before the handlers chain is invoked, e.g. to join application logs with traces. The trace ID
+	// is also stored in the gin.Context under TraceIDKey, so handlers can include it in their logs.
+	SetTraceIDHeader string
+	// NormalizePath, if set, is applied to the raw path of requests that matched no route before it's used
+	// in the transaction name, e.g. to collapse IDs into placeholders.
+	NormalizePath func(path string) string
+	// NormalizeIDs configures whether the raw path of requests that matched no route should be normalized
+	// with the built-in normalizer replacing numeric segments with ":id" and UUID segments with ":uuid",
+	// e.g. /files/42/3f1c6d4e-8f9a-4b1e-9c2d-7a6b5e4d3c2b becomes /files/:id/:uuid. NormalizePath takes precedence.
+	NormalizeIDs bool
+	// OnEventCaptured, if set, is called with the ID of every event captured by the middleware, i.e. recovered panics
+	// and, if enabled, errors of c.Errors, error responses and context errors, e.g. to count them in own metrics.
+	// It isn't called for events the SDK dropped before capturing (e.g. by BeforeSend).
+	OnEventCaptured func(eventID *sentry.EventID)
+	// TagRoute configures whether the matched route template (c.FullPath()) should be set as the route tag
+	// of the transaction. Unlike span data, tags are indexed and searchable. Requests that matched no route
+	// are not tagged, to keep the cardinality of the tag low.
+	TagRoute bool
+	// TagStatusCode configures whether the response status should be set as the status_code tag of the transaction.
+	TagStatusCode bool
+	// DeduplicatePanics configures whether a panic should be reported only once per request, e.g. when
+	// the middleware is registered twice or a deferred handler repanics, and the panic is recovered more than once.
+	DeduplicatePanics bool
+	// TransactionSource, if set, overrides the source of the transaction name, which Sentry uses to aggregate
+	// transactions. By default the source is sentry.SourceCustom for names returned by TransactionName (and other
+	// custom names), sentry.SourceRoute for names using the matched route template and sentry.SourceURL for names
+	// using the raw path.
+	//
+	// The source is set on the transaction span, so the SDK reports it as transaction_info.source
+	// in the transaction events, which dynamic sampling and metrics extraction rely on.
+	TransactionSource sentry.TransactionSource
+	// CaptureFormFieldNames configures whether the names (not the values) of the fields of url-encoded and multipart
+	// form bodies should be attached to the events as the form_field_names extra. The body is restored for the handlers.
+	// Bodies larger than 1MiB are not parsed, so large uploads aren't buffered.
+	CaptureFormFieldNames bool
+	// TransactionNameHeader, if set, is the name of a request header (e.g. X-Original-Route) holding the route the
+	// request should be named after, useful when a gateway rewrites the paths. Its value is prefixed with the method.
+	// Values longer than 200 characters are ignored and the default naming is used when the header is absent.
+	// As every distinct value becomes a distinct transaction, the header must be set (or stripped) by a trusted
+	// gateway, a client setting it would otherwise flood Sentry with transaction names; see AllowedTransactionNames.
+	TransactionNameHeader string
+	// AllowedTransactionNames, if not empty, lists the values of TransactionNameHeader which are accepted,
+	// the default naming is used for other values. It bounds the number of transaction names when the header
+	// can't be trusted.
+	AllowedTransactionNames []string
+	// TagContentTypes configures whether the media types of the request and the response should be added to
+	// the transactions as the request_content_type and response_content_type tags. Parameters such as charset are dropped.
+	TagContentTypes bool
+	// MaxBreadcrumbs, if positive, bounds the number of breadcrumbs added by this middleware per request
+	// (see AddRequestBreadcrumb and AddErrorBreadcrumbs), further breadcrumbs are dropped. It doesn't count breadcrumbs added by the handlers and, as the middleware's
+	// breadcrumbs are added to the hub, the SDK's ClientOptions.MaxBreadcrumbs still applies on top of it.
+	MaxBreadcrumbs int
+	// WebSocketMode configures how WebSocket upgrade requests are traced, as by default their transactions
+	// last until the connections are closed. See WebSocketSkip and WebSocketShortSpan.
+	WebSocketMode WebSocketMode
+	// SpanPerHandler configures whether a gin.handler child span, described with the name of the route handler,
+	// should cover the handlers chain, so the time spent in the handlers is told apart from the time spent in the middleware.
+	SpanPerHandler bool
+	// Release, if set, overrides the release of the events (including transactions) reported for requests handled
+	// by the middleware, taking precedence over ClientOptions.Release and the SENTRY_RELEASE environment variable,
+	// e.g. Release: os.Getenv("APP_RELEASE"). It's read once when the middleware is created.
+	Release string
+	// ErrorLevel, if set, returns the level of the event reported for an error collected in c.Errors
+	// when CaptureErrors is set, e.g. to report validation errors as sentry.LevelWarning. Errors are reported as
+	// sentry.LevelError otherwise.
+	ErrorLevel func(err error) sentry.Level
+	// FlushCircuitBreaker, if both its fields are positive, stops blocking on the delivery of panic events
+	// (see WaitForDelivery) for a while once several flushes in a row have timed out, e.g. during a Sentry outage.
+	FlushCircuitBreaker FlushCircuitBreaker
+	// PanicToError, if set, converts a recovered value into the error reported for the panic, e.g. to report
+	// fmt.Stringer panics with a readable type and message. The original value is reported as is if it returns nil.
+	// The other options (e.g. LevelForPanic, OnRecover and Repanic) still get the original value.
+	PanicToError func(recovered interface{}) error
+	// TagUserAgent configures whether the user agent of the request should be added to the events (including
+	// transactions) as the user_agent tag. It's normalized with NormalizeUserAgent to limit the cardinality of the tag.
+	TagUserAgent bool
+	// NormalizeUserAgent, if set, replaces UserAgentFamily as the normalization of the user_agent tag,
+	// e.g. func(ua string) string { return ua } tags the raw user agents.
+	NormalizeUserAgent func(userAgent string) string
+	// TagReferer configures whether the host of the Referer header should be added to the events (including
+	// transactions) as the referer tag. Only the host is kept to limit the cardinality of the tag.
+	TagReferer bool
+	// InjectLogger, if set, is the base of a logger stored on the gin.Context (under LoggerKey) and on the request
+	// context of every request, carrying the trace_id and span_id attributes of the transaction of the request.
+	// The handlers get it with LoggerFromContext.
+	//
+	// As it's a *slog.Logger, the package requires Go 1.21 or later since this option was added,
+	// which is a breaking change for modules built with older Go versions.
+	InjectLogger *slog.Logger
+	// FinalTransactionName, if set, is called once the handlers chain returns, right before the transaction is finished,
+	// to rename the transaction when the name is only known after the handler ran (e.g. a GraphQL operation name stored
+	// with c.Set). The name is kept if it returns an empty string. The returned names should be low-cardinality,
+	// as unique names (e.g. containing IDs) defeat the aggregation of the transactions.
+	FinalTransactionName func(c *gin.Context) string
+	// RecordTTFB configures whether the time elapsed until the first byte of the response has been written,
+	// in milliseconds, should be recorded in the ttfb_ms span data, e.g. to diagnose slow streaming endpoints.
+	RecordTTFB bool
+	// SkipMethods lists the HTTP methods (e.g. OPTIONS) of the requests that should not be traced,
+	// matched case-insensitively. Panics of such requests are still reported.
+	SkipMethods []string
+	// AnonymizeIP, if set, replaces the IP address of the user with the returned value when SetClientIP is set,
+	// e.g. TruncateIP or a hash, to correlate the events of a client without storing its address. It's applied
+	// to the address returned by UserExtractor as well.
+	AnonymizeIP func(ip string) string
+	// RecordHandlerCount configures whether the number of handlers in the chain of the route, including
+	// the middleware, should be recorded in the gin.handler_count span data, e.g. to spot over-stacked routes.
+	RecordHandlerCount bool
+	// TraceContextHeader, if set together with DecodeTraceContext, is the name of a request header carrying
+	// the trace context in a custom encoding, e.g. for queued requests replayed by internal callers. It's used when
+	// the request has no sentry-trace header, before the traceparent header of ContinueFromTraceparent.
+	TraceContextHeader string
+	// DecodeTraceContext decodes the value of TraceContextHeader. The header is ignored when it returns false
+	// or a span context without trace or span ID.
+	DecodeTraceContext func(value string) (SpanContext, bool)
+	// PromoteContextKeys lists the keys of values stored in the gin.Context (e.g. tenant_id set by an earlier middleware)
+	// which should be added to the transactions as tags once the handlers chain returns. Only strings, booleans and
+	// numbers are promoted, other values and values longer than 200 characters are skipped, as are the keys past the 20th.
+	PromoteContextKeys []string
+	// AttachGoroutineDump configures whether the stacks of all goroutines should be attached to the events of
+	// recovered panics as the goroutines.txt attachment, e.g. to debug deadlock-adjacent panics. The dump is truncated
+	// to 64KiB. Collecting it stops the world for a moment, which gets longer with the number of goroutines.
+	AttachGoroutineDump bool
+	// EnvironmentHeader, if set, is the name of a request header (e.g. X-Environment) overriding the environment
+	// of the events (including transactions) reported for the request, e.g. for a service shared by several environments.
+	// Only the values listed in AllowedEnvironments are accepted, the environment configured in the SDK is kept otherwise.
+	EnvironmentHeader string
+	// AllowedEnvironments lists the values of EnvironmentHeader which are accepted, the header is ignored if it's empty.
+	AllowedEnvironments []string
+	// SkipStaticAssets configures whether requests for static assets, i.e. whose path ends with one of
+	// StaticExtensions, should not be traced, e.g. for assets served with Static. Panics of such requests are still reported.
+	SkipStaticAssets bool
+	// StaticExtensions lists the extensions of the paths of static assets, matched case-insensitively.
+	// Defaults to common web assets (.js, .css, .png, .jpg, .svg, .woff2, ...).
+	StaticExtensions []string
+	// RecordContentNegotiation configures whether the Accept header of the request and the Content-Type of the response
+	// should be recorded in the http.request.accept and http.response.content_type span data, e.g. to spot the clients
+	// stuck on a legacy representation of routes serving several ones.
+	RecordContentNegotiation bool
+	// AfterRequest, if set, is called exactly once per traced request, right before the transaction is
+	// finished: once the handlers chain returns or, if a handler panicked, once the panic has been recovered
+	// (the status of the span is then sentry.SpanStatusInternalError). It's the general-purpose extension point
+	// to set tags, data or the status of the transaction, and runs before FinalTransactionName and BeforeSpanFinish.
+	AfterRequest func(c *gin.Context, span *sentry.Span)
+	// BypassWhen, if set, reports whether the middleware should be bypassed for the request, e.g. for trusted health
+	// probes identified by their address or a header. Bypassed requests incur no overhead: no hub is cloned, no
+	// transaction is started and, unlike with IgnorePaths, their panics are neither recovered nor reported.
+	BypassWhen func(c *gin.Context) bool
+	// StructuredValidationErrors configures whether validator.ValidationErrors collected in c.Errors (e.g. returned by
+	// c.ShouldBindJSON) should be reported with the validation context, mapping the invalid fields to the failed tags,
+	// when CaptureErrors is set.
+	StructuredValidationErrors bool
+	// DetectDoubleRegistration configures whether the middleware should pass through requests already handled
+	// by another instance of the middleware, e.g. registered with both engine.Use and a group Use, instead of starting
+	// a nested transaction and recovering the panics twice. A warning is logged to Logger the first time.
+	DetectDoubleRegistration bool
+	// ResponseHeaderTags maps the names of response headers to the names of tags, the values of the headers set
+	// by the handlers are added to the transactions as tags once the handlers chain returns, e.g. {"X-Cache": "cache"}
+	// to compute cache hit rates. Missing headers and values longer than 200 characters are skipped.
+	ResponseHeaderTags map[string]string
+	// TraceContextFromRequest, if set, returns the span continued by the request, e.g. the span of another tracing
+	// system stored in the request context by a middleware registered before this one, so the spans of both systems
+	// share a trace ID. It's used when the request has no sentry-trace header, before TraceContextHeader and
+	// the traceparent header, and its result is ignored when it returns false or a span context without trace
+	// or span ID. See the github.com/Kichiyaki/sentrygin/otelbridge module for OpenTelemetry.
+	//
+	// The request context of the handlers is derived from the original one, so the values it carries are kept
+	// alongside the transaction either way.
+	TraceContextFromRequest func(r *http.Request) (SpanContext, bool)
 }
 
 type handler struct {
-	repanic         bool
-	waitForDelivery bool
-	timeout         time.Duration
+	repanic          bool
+	waitForDelivery  bool
+	timeout          time.Duration
+	ignorePaths      *pathMatcher
+	captureErrors    bool
+	errorTypes       gin.ErrorType
+	traceHeaders     bool
+	name             func(c *gin.Context) string
+	captureBody      bool
+	bodyLimit        int
+	tags             map[string]string
+	keepURLQuery     bool
+	sampler          func(c *gin.Context) (float64, bool)
+	scrubHeaders     []string
+	reuseHub         bool
+	beforeCapture    func(c *gin.Context, hub *sentry.Hub, recovered interface{})
+	captureStatus    func(status int) bool
+	recordDuration   bool
+	onRecover        func(c *gin.Context, recovered interface{}, eventID *sentry.EventID)
+	userExtractor    func(c *gin.Context) sentry.User
+	setClientIP      bool
+	operation        string
+	fingerprint      bool
+	attachParams     bool
+	scrubParams      map[string]struct{}
+	breadcrumb       bool
+	errorBreadcrumbs bool
+	abort            bool
+	levelForPanic    func(recovered interface{}) sentry.Level
+	minDuration      time.Duration
+	headerContext    []string
+	dropStacktrace   bool
+	tagHandlerName   bool
+	captureCtxErrors bool
+	processors       []sentry.EventProcessor
+	unmatchedName    string
+	respHeaderCtx    []string
+	disableTracing   bool
+	beforeFinish     func(c *gin.Context, span *sentry.Span) bool
+	traceparent      bool
+	payloadSizes     bool
+	ctxHubKey        interface{}
+	skipRecover      func(recovered interface{}) bool
+	tagNotAllowed    bool
+	extraContext     func(c *gin.Context) map[string]interface{}
+	extraCtxName     string
+	captureQuery     bool
+	scrubQuery       map[string]struct{}
+	samplerCache     *samplerCache
+	respectDeadline  bool
+	traceIDHeader    string
+	normalizePath    func(path string) string
+	onCaptured       func(eventID *sentry.EventID)
+	tagRoute         bool
+	tagStatusCode    bool
+	deduplicate      bool
+	source           sentry.TransactionSource
+	formFieldNames   bool
+	nameHeader       string
+	allowedNames     map[string]struct{}
+	tagContentTypes  bool
+	maxBreadcrumbs   int
+	wsMode           WebSocketMode
+	spanPerHandler   bool
+	errorLevel       func(err error) sentry.Level
+	// client is bound to the hub of every request if withClient is set, even if it's nil, see NewWithClient.
+	client           *sentry.Client
+	withClient       bool
+	breaker          *flushBreaker
+	panicToError     func(recovered interface{}) error
+	tagUserAgent     bool
+	normalizeUA      func(userAgent string) string
+	tagReferer       bool
+	logger           *slog.Logger
+	finalName        func(c *gin.Context) string
+	recordTTFB       bool
+	skipMethods      []string
+	anonymizeIP      func(ip string) string
+	handlerCount     bool
+	traceCtxHeader   string
+	decodeTraceCtx   func(value string) (SpanContext, bool)
+	promoteKeys      []string
+	goroutineDump    bool
+	envHeader        string
+	allowedEnvs      map[string]struct{}
+	staticExts       map[string]struct{}
+	negotiation      bool
+	afterRequest     func(c *gin.Context, span *sentry.Span)
+	bypass           func(c *gin.Context) bool
+	validationErrors bool
+	detectDouble     bool
+	warnLogger       *log.Logger
+	doubleWarning    *sync.Once
+	respHeaderTags   map[string]string
+	traceCtxFromReq  func(r *http.Request) (SpanContext, bool)
+	// pathNames is set by NewHTTP, whose requests all match no route, so UnmatchedTransactionName doesn't apply.
+	pathNames bool
+	// wrapHub, if set, replaces the hub used to report panics, it's meant to be used by tests only.
+	wrapHub func(hub *sentry.Hub) sentryHub
+}
+
+// sentryHub is the subset of *sentry.Hub methods used to report panics.
+type sentryHub interface {
+	Scope() *sentry.Scope
+	RecoverWithContext(ctx context.Context, err interface{}) *sentry.EventID
+	Flush(timeout time.Duration) bool
+}
+
+// handlerState is the state of the middleware kept across requests,
+// it's shared by the handlers NewWithProvider builds for distinct options.
+type handlerState struct {
+	mu            sync.Mutex
+	samplerCaches map[int]*samplerCache
+	breakers      map[FlushCircuitBreaker]*flushBreaker
+	doubleWarning sync.Once
+	uninitWarning sync.Once
+	profWarning   sync.Once
+}
+
+// samplerCache returns the sampler cache of the given size, the handlers with the same SamplerCacheSize share it.
+func (s *handlerState) samplerCache(size int) *samplerCache {
+	if size <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.samplerCaches == nil {
+		s.samplerCaches = make(map[int]*samplerCache)
+	}
+	sc, ok := s.samplerCaches[size]
+	if !ok {
+		sc = newSamplerCache(size)
+		s.samplerCaches[size] = sc
+	}
+
+	return sc
+}
+
+// breaker returns the circuit breaker configured with cfg, the handlers with the same FlushCircuitBreaker share it.
+func (s *handlerState) breaker(cfg FlushCircuitBreaker) *flushBreaker {
+	if cfg.Failures <= 0 || cfg.Cooldown <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.breakers == nil {
+		s.breakers = make(map[FlushCircuitBreaker]*flushBreaker)
+	}
+	b, ok := s.breakers[cfg]
+	if !ok {
+		b = newFlushBreaker(cfg)
+		s.breakers[cfg] = b
+	}
+
+	return b
+}
+
+// warn logs the warnings of WarnIfUninitialized and EnableProfiling, if opts set them, the first time they're set.
+func (s *handlerState) warn(opts Options) {
+	if opts.WarnIfUninitialized {
+		s.uninitWarning.Do(func() {
+			warnIfUninitialized(opts)
+		})
+	}
+	if opts.EnableProfiling {
+		s.profWarning.Do(func() {
+			warnIfNotProfiling(opts, sentry.CurrentHub().Client())
+		})
+	}
 }
 
 // New returns a function that satisfies gin.HandlerFunc interface
 // It can be used with Use() methods.
+//
+// Transactions started by the middleware are profiled according to the ProfilesSampleRate
+// configured in the SDK (sentry.ClientOptions), the profile is attached to the finished transaction.
+//
+// It's NewWithProvider with a provider returning opts, the warnings of WarnIfUninitialized and EnableProfiling
+// are logged right away though.
 func New(opts Options) gin.HandlerFunc {
+	state := &handlerState{}
+	state.warn(opts)
+
+	return withProvider(func(*gin.Context) Options { return opts }, state)
+}
+
+// NewWithClient is like New, but reports to client instead of the client of the current hub, e.g. to report
+// the requests of distinct tenants to distinct projects. Every request gets its own hub bound to client,
+// even if the request context already carries a hub, so ReuseHub doesn't apply.
+//
+// The hub is stored on both the gin.Context and the request context, so GetHubFromContext and
+// sentry.GetHubFromContext(c.Request.Context()) return it to the handlers, while sentry.CurrentHub
+// (and the package-level functions such as sentry.CaptureException) keep using the global client.
+//
+// A nil client disables reporting: the hubs of the requests are bound to no client, so nothing is sent,
+// the global client isn't used as a fallback.
+func NewWithClient(client *sentry.Client, opts Options) gin.HandlerFunc {
+	if opts.WarnIfUninitialized && client == nil {
+		warn(opts.Logger, "sentrygin: no client has been provided, events won't be sent")
+	}
+	warnIfNotProfiling(opts, client)
+
+	h := newHandler(opts, &handlerState{})
+	h.client = client
+	h.withClient = true
+
+	return h.handle
+}
+
+// warnIfUninitialized logs a warning if WarnIfUninitialized is set and no client is bound to sentry.CurrentHub().
+func warnIfUninitialized(opts Options) {
+	if opts.WarnIfUninitialized && sentry.CurrentHub().Client() == nil {
+		warn(opts.Logger, "sentrygin: no client is bound to the current hub, events won't be sent (has sentry.Init been called?)")
+	}
+}
+
+// warnIfNotProfiling logs a warning if EnableProfiling is set but client doesn't profile transactions.
+func warnIfNotProfiling(opts Options, client *sentry.Client) {
+	if opts.EnableProfiling && client != nil && client.Options().ProfilesSampleRate <= 0 {
+		warn(opts.Logger, "sentrygin: EnableProfiling is set, but the ProfilesSampleRate of the client is 0, transactions won't be profiled")
+	}
+}
+
+// warn logs msg to logger, or to the standard logger if it's nil.
+func warn(logger *log.Logger, msg string) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Print(msg)
+}
+
+// NewWithProvider is like New, but resolves the options for every request, e.g. to wait for delivery
+// of panic events of /admin routes only. Defaults are applied to the returned options the same way as in New.
+//
+// The handler configured with the returned options is built the first time they're returned and reused afterwards,
+// the options are the same if all their fields are: funcs have to be the same function or closure (a closure
+// created by every call of the provider is a distinct one), pointers have to point to the same value and maps
+// and slices have to be deeply equal. Up to 16 distinct options are cached, a handler is built for every request
+// returning other ones. Providers should thus return one of a few Options values defined once, e.g. as variables.
+//
+// The state kept across requests is shared by all the requests: the handlers with the same SamplerCacheSize share
+// the cache of sampling decisions, the ones with the same FlushCircuitBreaker share the breaker, and the warnings
+// of WarnIfUninitialized and EnableProfiling (checked on the first request setting them) and DetectDoubleRegistration
+// are logged once.
+func NewWithProvider(provider func(c *gin.Context) Options) gin.HandlerFunc {
+	return withProvider(provider, &handlerState{})
+}
+
+// withProvider returns a handler resolving the options of every request with provider, see NewWithProvider.
+func withProvider(provider func(c *gin.Context) Options, state *handlerState) gin.HandlerFunc {
+	handlers := &handlerCache{}
+
+	return func(c *gin.Context) {
+		opts := optionsPool.Get().(*Options)
+		*opts = provider(c)
+		h := handlers.get(opts)
+		if h == nil {
+			state.warn(*opts)
+			h = newHandler(*opts, state)
+			handlers.add(*opts, h)
+		}
+		*opts = Options{}
+		optionsPool.Put(opts)
+
+		h.handle(c)
+	}
+}
+
+// newHandler returns a handler configured with opts, the state kept across requests is stored in state.
+func newHandler(opts Options, state *handlerState) *handler {
 	if opts.Timeout == 0 {
 		opts.Timeout = 2 * time.Second
 	}
+	if opts.Operation == "" {
+		opts.Operation = "http.server"
+	}
+	if opts.ExtraContextName == "" {
+		opts.ExtraContextName = "request_meta"
+	}
+	if opts.RequestBodyLimit <= 0 {
+		opts.RequestBodyLimit = 4096
+	}
+	if opts.ScrubHeaders == nil {
+		opts.ScrubHeaders = defaultScrubHeaders
+	}
+	if opts.NormalizeUserAgent == nil {
+		opts.NormalizeUserAgent = UserAgentFamily
+	}
+	if opts.SkipRecover == nil {
+		opts.SkipRecover = isErrAbortHandler
+	}
+	if opts.ReportErrorTypes == 0 {
+		opts.ReportErrorTypes = gin.ErrorTypePrivate
+	}
 
-	return (&handler{
-		repanic:         opts.Repanic,
-		timeout:         opts.Timeout,
-		waitForDelivery: opts.WaitForDelivery,
-	}).handle
+	return &handler{
+		repanic:          opts.Repanic,
+		timeout:          opts.Timeout,
+		waitForDelivery:  opts.WaitForDelivery,
+		ignorePaths:      newPathMatcher(opts.IgnorePaths),
+		captureErrors:    opts.CaptureErrors,
+		errorTypes:       opts.ReportErrorTypes,
+		traceHeaders:     opts.SetTraceHeadersOnResponse,
+		name:             opts.TransactionName,
+		captureBody:      opts.CaptureRequestBody,
+		bodyLimit:        opts.RequestBodyLimit,
+		tags:             defaultTags(opts),
+		keepURLQuery:     opts.KeepURLQuery,
+		sampler:          opts.TransactionSampler,
+		scrubHeaders:     canonicalHeaderKeys(opts.ScrubHeaders),
+		reuseHub:         opts.ReuseHub,
+		beforeCapture:    opts.BeforeCapture,
+		captureStatus:    opts.CaptureResponseErrors,
+		recordDuration:   opts.RecordDurationMeasurement,
+		onRecover:        opts.OnRecover,
+		userExtractor:    opts.UserExtractor,
+		setClientIP:      opts.SetClientIP,
+		operation:        opts.Operation,
+		fingerprint:      opts.FingerprintByRoute,
+		attachParams:     opts.AttachRouteParams,
+		scrubParams:      stringSet(opts.ScrubParams),
+		breadcrumb:       opts.AddRequestBreadcrumb,
+		errorBreadcrumbs: opts.AddErrorBreadcrumbs,
+		abort:            opts.AbortWithInternalError,
+		levelForPanic:    opts.LevelForPanic,
+		minDuration:      opts.MinDuration,
+		headerContext:    canonicalHeaderKeys(opts.HeaderContext),
+		dropStacktrace:   opts.DropStacktrace,
+		tagHandlerName:   opts.TagHandlerName,
+		captureCtxErrors: opts.CaptureContextErrors,
+		processors:       eventProcessors(opts),
+		unmatchedName:    opts.UnmatchedTransactionName,
+		respHeaderCtx:    canonicalHeaderKeys(opts.ResponseHeaderContext),
+		disableTracing:   opts.DisableTracing,
+		beforeFinish:     opts.BeforeSpanFinish,
+		traceparent:      opts.ContinueFromTraceparent,
+		payloadSizes:     opts.RecordPayloadSizes,
+		ctxHubKey:        opts.ContextHubKey,
+		skipRecover:      opts.SkipRecover,
+		tagNotAllowed:    opts.TagMethodNotAllowed,
+		extraContext:     opts.ExtraContext,
+		extraCtxName:     opts.ExtraContextName,
+		captureQuery:     opts.CaptureQueryParams,
+		scrubQuery:       stringSet(lowerAll(opts.ScrubQueryParams)),
+		samplerCache:     state.samplerCache(opts.SamplerCacheSize),
+		respectDeadline:  opts.RespectContextDeadline,
+		traceIDHeader:    opts.SetTraceIDHeader,
+		normalizePath:    normalizer(opts),
+		onCaptured:       opts.OnEventCaptured,
+		tagRoute:         opts.TagRoute,
+		tagStatusCode:    opts.TagStatusCode,
+		deduplicate:      opts.DeduplicatePanics,
+		source:           opts.TransactionSource,
+		formFieldNames:   opts.CaptureFormFieldNames,
+		nameHeader:       opts.TransactionNameHeader,
+		allowedNames:     stringSet(opts.AllowedTransactionNames),
+		tagContentTypes:  opts.TagContentTypes,
+		maxBreadcrumbs:   opts.MaxBreadcrumbs,
+		wsMode:           opts.WebSocketMode,
+		spanPerHandler:   opts.SpanPerHandler,
+		errorLevel:       opts.ErrorLevel,
+		breaker:          state.breaker(opts.FlushCircuitBreaker),
+		panicToError:     opts.PanicToError,
+		tagUserAgent:     opts.TagUserAgent,
+		normalizeUA:      opts.NormalizeUserAgent,
+		tagReferer:       opts.TagReferer,
+		logger:           opts.InjectLogger,
+		finalName:        opts.FinalTransactionName,
+		recordTTFB:       opts.RecordTTFB,
+		skipMethods:      append([]string(nil), opts.SkipMethods...),
+		anonymizeIP:      opts.AnonymizeIP,
+		handlerCount:     opts.RecordHandlerCount,
+		traceCtxHeader:   opts.TraceContextHeader,
+		decodeTraceCtx:   opts.DecodeTraceContext,
+		promoteKeys:      append([]string(nil), opts.PromoteContextKeys...),
+		goroutineDump:    opts.AttachGoroutineDump,
+		envHeader:        opts.EnvironmentHeader,
+		allowedEnvs:      stringSet(opts.AllowedEnvironments),
+		staticExts:       staticExtensions(opts),
+		negotiation:      opts.RecordContentNegotiation,
+		afterRequest:     opts.AfterRequest,
+		bypass:           opts.BypassWhen,
+		validationErrors: opts.StructuredValidationErrors,
+		detectDouble:     opts.DetectDoubleRegistration,
+		warnLogger:       opts.Logger,
+		doubleWarning:    &state.doubleWarning,
+		respHeaderTags:   copyMap(opts.ResponseHeaderTags),
+		traceCtxFromReq:  opts.TraceContextFromRequest,
+	}
 }
 
 func (h *handler) handle(c *gin.Context) {
+	if h.bypass != nil && h.bypass(c) {
+		c.Next()
+		return
+	}
+
+	if c.GetBool(handledKey) && h.detectDouble {
+		h.doubleWarning.Do(func() {
+			warn(h.warnLogger, "sentrygin: the middleware has been registered twice for "+routeOf(c)+", the second one is skipped")
+		})
+		c.Next()
+		return
+	}
+	c.Set(handledKey, true)
+
+	start := time.Now()
 	ctx := c.Request.Context()
 
 	hub := sentry.GetHubFromContext(ctx)
-	if hub == nil {
-		hub = sentry.CurrentHub().Clone()
+	switch {
+	case h.withClient:
+		hub = sentry.NewHub(h.client, sentry.NewScope())
+		ctx = sentry.SetHubOnContext(ctx, hub)
+	case hub == nil:
+		if h.reuseHub {
+			hub = sentry.CurrentHub()
+			hub.PushScope()
+			defer hub.PopScope()
+		} else {
+			// Cloned hubs are not pooled: the hub outlives the request whenever it's referenced
+			// by the context of a span, a goroutine spawned by a handler or a copy of the gin.Context,
+			// and neither the hub nor its scope can be fully reset (e.g. the last event ID and event processors).
+			// The scope can't be pooled on its own either, the SDK can only clone the current scope into a new one.
+			// The clone only costs a few allocations per request (see BenchmarkHubPerRequest),
+			// ReuseHub avoids them if it matters.
+			hub = sentry.CurrentHub().Clone()
+		}
 		ctx = sentry.SetHubOnContext(ctx, hub)
 	}
+	if h.ctxHubKey != nil {
+		ctx = context.WithValue(ctx, h.ctxHubKey, hub)
+	}
 
-	span := sentry.StartSpan(
-		ctx,
-		"http.server",
-		sentry.TransactionName(c.Request.Method+" "+c.Request.URL.Path),
-		sentry.ContinueFromRequest(c.Request),
-	)
+	c.Set(HubKey, hub)
+	h.configureScope(c, hub)
+
+	if h.skipTracing(c) {
+		h.handleUntraced(c, hub, ctx)
+		return
+	}
+
+	name, source := h.transactionName(c)
+	if h.source != "" {
+		source = h.source
+	}
+
+	spanOpts := []sentry.SpanOption{
+		sentry.WithTransactionName(name),
+		sentry.WithTransactionSource(source),
+		h.continueFromRequest(c.Request),
+	}
+	if h.sampler != nil {
+		if rate, ok := h.sample(c); ok {
+			spanOpts = append(spanOpts, sampleRate(rate))
+		}
+	}
+
+	operation := h.operation
+	upgrade := h.wsMode == WebSocketShortSpan && isWebSocketUpgrade(c.Request)
+	if upgrade {
+		operation = webSocketOperation
+	}
+
+	span := sentry.StartSpan(ctx, operation, spanOpts...)
+	defer h.finishSpan(c, span)
+	recordDuration := func() {
+		setSpanData(span, "duration_ms", float64(time.Since(start))/float64(time.Millisecond))
+	}
+
+	// The span of an upgrade request is finished once the handshake is done, it mustn't be modified afterwards.
+	var hijacker *hijackWriter
+	if upgrade {
+		hijacker = &hijackWriter{
+			ResponseWriter: c.Writer,
+			onHijack: func() {
+				span.Status = sentry.SpanStatusOK
+				setSpanData(span, "http.response.status_code", http.StatusSwitchingProtocols)
+				if h.recordDuration {
+					recordDuration()
+				}
+				h.finishSpan(c, span)
+			},
+		}
+		c.Writer = hijacker
+	}
+	var ttfb *ttfbWriter
+	if h.recordTTFB {
+		ttfb = &ttfbWriter{ResponseWriter: c.Writer, start: start}
+		c.Writer = ttfb
+	}
+	if h.recordDuration {
+		defer func() {
+			if hijacker == nil || !hijacker.hijacked {
+				recordDuration()
+			}
+		}()
+	}
+
+	setSpanData(span, "http.request.method", c.Request.Method)
+	setSpanData(span, "http.url", h.spanURL(c.Request))
+	if h.attachParams {
+		h.setParamsData(span, c.Params)
+	}
+	if h.captureQuery && c.Request.URL.RawQuery != "" {
+		setSpanData(span, "http.query", h.queryData(c.Request))
+	}
+
+	if h.traceHeaders {
+		setTraceHeaders(c, span)
+	}
+	if h.traceIDHeader != "" {
+		traceID := span.TraceID.String()
+		c.Header(h.traceIDHeader, traceID)
+		c.Set(TraceIDKey, traceID)
+	}
+
+	h.setRequest(c, hub, span.Context())
+
+	defer h.recoverWithSentry(hub, c)
+
+	// The status is overwritten once the handlers chain returns, so it is only
+	// reported as is when one of the handlers panicked.
+	span.Status = sentry.SpanStatusInternalError
+
+	// The request headers are read before the handlers run, as they may replace the request.
+	reqContentType := c.ContentType()
+	accept := c.GetHeader("Accept")
+	lastEventID := hub.LastEventID()
+	if h.spanPerHandler {
+		h.nextWithSpan(c, span)
+	} else {
+		c.Next()
+	}
+
+	if hijacker != nil && hijacker.hijacked {
+		h.report(hub, c, lastEventID)
+		return
+	}
+
+	span.Status = sentry.HTTPtoSpanStatus(c.Writer.Status())
+	if route := c.FullPath(); h.tagRoute && route != "" {
+		span.SetTag("route", route)
+	}
+	if h.tagStatusCode {
+		span.SetTag("status_code", strconv.Itoa(c.Writer.Status()))
+	}
+	if h.tagNotAllowed && c.Writer.Status() == http.StatusMethodNotAllowed {
+		span.SetTag("http.method_not_allowed", "true")
+	}
+	if h.tagHandlerName {
+		if name := c.HandlerName(); name != "" {
+			span.SetTag("gin.handler", name)
+		}
+	}
+	if h.tagContentTypes {
+		if contentType := mediaType(reqContentType); contentType != "" {
+			span.SetTag("request_content_type", contentType)
+		}
+		if contentType := mediaType(c.Writer.Header().Get("Content-Type")); contentType != "" {
+			span.SetTag("response_content_type", contentType)
+		}
+	}
+	if h.minDuration > 0 && time.Since(start) < h.minDuration {
+		span.Sampled = sentry.SampledFalse
+	}
+	setSpanData(span, "http.response.status_code", c.Writer.Status())
+	if ttfb != nil && ttfb.ttfb > 0 {
+		setSpanData(span, "ttfb_ms", float64(ttfb.ttfb)/float64(time.Millisecond))
+	}
+	if h.payloadSizes {
+		setPayloadSizesData(span, c)
+	}
+	if h.negotiation {
+		if accept != "" {
+			setSpanData(span, "http.request.accept", accept)
+		}
+		if contentType := c.Writer.Header().Get("Content-Type"); contentType != "" {
+			setSpanData(span, "http.response.content_type", contentType)
+		}
+	}
+	for header, tag := range h.respHeaderTags {
+		if value := c.Writer.Header().Get(header); value != "" && len(value) <= maxTagValueLength {
+			span.SetTag(tag, value)
+		}
+	}
+	if len(h.promoteKeys) > 0 {
+		promoteContextKeys(span, c, h.promoteKeys)
+	}
+	if h.handlerCount {
+		setSpanData(span, "gin.handler_count", len(c.HandlerNames()))
+	}
+	h.report(hub, c, lastEventID)
+}
+
+func stringSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	return set
+}
+
+// configureScope sets the request specific data on the scope. The hub is cloned (or a scope pushed)
+// per request, so nothing set here leaks into other requests.
+func (h *handler) configureScope(c *gin.Context, hub *sentry.Hub) {
+	scope := hub.Scope()
+
+	for _, processor := range h.processors {
+		scope.AddEventProcessor(processor)
+	}
+	if env := c.GetHeader(h.envHeader); h.envHeader != "" && env != "" {
+		if _, ok := h.allowedEnvs[env]; ok {
+			scope.AddEventProcessor(func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+				event.Environment = env
+				return event
+			})
+		}
+	}
+
+	if len(h.tags) > 0 {
+		scope.SetTags(h.tags)
+	}
+	if ua := c.Request.UserAgent(); h.tagUserAgent && ua != "" {
+		scope.SetTag("user_agent", h.normalizeUA(ua))
+	}
+	if referer := c.Request.Referer(); h.tagReferer && referer != "" {
+		scope.SetTag("referer", refererHost(referer))
+	}
+
+	var user sentry.User
+	if h.userExtractor != nil {
+		user = h.userExtractor(c)
+	}
+	if h.setClientIP && user.IPAddress == "" {
+		user.IPAddress = c.ClientIP()
+	}
+	if h.setClientIP && h.anonymizeIP != nil && user.IPAddress != "" {
+		user.IPAddress = h.anonymizeIP(user.IPAddress)
+	}
+	if !user.IsEmpty() {
+		scope.SetUser(user)
+	}
+
+	if len(h.headerContext) > 0 {
+		if hc := headersContext(c.Request.Header, h.headerContext); hc != nil {
+			scope.SetContext("request_headers", hc)
+		}
+	}
+
+	if h.extraContext != nil {
+		if extra := h.callExtraContext(c); len(extra) > 0 {
+			scope.SetContext(h.extraCtxName, extra)
+		}
+	}
+
+	if h.breadcrumb {
+		h.addBreadcrumb(c, hub, &sentry.Breadcrumb{
+			Type:     "http",
+			Category: "http",
+			Data: map[string]interface{}{
+				"method": c.Request.Method,
+				"url":    c.Request.URL.Path,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// addBreadcrumb adds a breadcrumb to the hub unless the MaxBreadcrumbs limit of the request has been reached.
+func (h *handler) addBreadcrumb(c *gin.Context, hub *sentry.Hub, breadcrumb *sentry.Breadcrumb) {
+	if h.maxBreadcrumbs > 0 {
+		count := c.GetInt(breadcrumbCountKey)
+		if count >= h.maxBreadcrumbs {
+			return
+		}
+		c.Set(breadcrumbCountKey, count+1)
+	}
+
+	hub.AddBreadcrumb(breadcrumb, nil)
+}
+
+// callExtraContext calls the ExtraContext callback, making sure that its panic doesn't break the request.
+func (h *handler) callExtraContext(c *gin.Context) (extra map[string]interface{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			sentry.Logger.Printf("sentrygin: ExtraContext panicked: %v", err)
+			extra = nil
+		}
+	}()
+
+	return h.extraContext(c)
+}
+
+// eventProcessors returns the event processors added to the scope of every request.
+func eventProcessors(opts Options) []sentry.EventProcessor {
+	var processors []sentry.EventProcessor
+
+	if dist := opts.Dist; dist != "" {
+		processors = append(processors, func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+			event.Dist = dist
+			return event
+		})
+	}
+
+	if serverName := opts.ServerName; serverName != "" {
+		processors = append(processors, func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+			event.ServerName = serverName
+			return event
+		})
+	}
+
+	if release := opts.Release; release != "" {
+		processors = append(processors, func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+			event.Release = release
+			return event
+		})
+	}
+
+	processors = append(processors, opts.EventProcessors...)
+
+	return processors
+}
+
+// staticExtensions returns the set of StaticExtensions, or nil if SkipStaticAssets is not set.
+// The extensions are lowercased and prefixed with a dot if needed.
+func staticExtensions(opts Options) map[string]struct{} {
+	if !opts.SkipStaticAssets {
+		return nil
+	}
+
+	exts := opts.StaticExtensions
+	if len(exts) == 0 {
+		exts = defaultStaticExtensions
+	}
+
+	set := make(map[string]struct{}, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = struct{}{}
+	}
+
+	return set
+}
+
+func lowerAll(values []string) []string {
+	lower := make([]string, len(values))
+	for i, v := range values {
+		lower[i] = strings.ToLower(v)
+	}
+
+	return lower
+}
+
+func normalizer(opts Options) func(path string) string {
+	if opts.NormalizePath == nil && opts.NormalizeIDs {
+		return normalizeIDs
+	}
+
+	return opts.NormalizePath
+}
+
+// defaultTags returns the tags set on the scope of every request. DefaultTags are copied,
+// so the caller can't modify them once the middleware is created.
+func defaultTags(opts Options) map[string]string {
+	if len(opts.DefaultTags) == 0 && !opts.TagGinMode {
+		return nil
+	}
+
+	tags := make(map[string]string, len(opts.DefaultTags)+1)
+	for k, v := range opts.DefaultTags {
+		tags[k] = v
+	}
+	if opts.TagGinMode {
+		tags["gin.mode"] = gin.Mode()
+	}
+
+	return tags
+}
+
+// copyMap returns a copy of m, so the caller can't modify it once the middleware is created.
+func copyMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
+
+// continueFromRequest returns a span option continuing the trace the request is part of.
+func (h *handler) continueFromRequest(r *http.Request) sentry.SpanOption {
+	if r.Header.Get(sentry.SentryTraceHeader) != "" {
+		return sentry.ContinueFromRequest(r)
+	}
+
+	if h.traceCtxFromReq != nil {
+		if sc, ok := h.traceCtxFromReq(r); ok {
+			if trace, ok := sc.sentryTrace(); ok {
+				return sentry.ContinueFromHeaders(trace, r.Header.Get(sentry.SentryBaggageHeader))
+			}
+		}
+	}
+
+	if value := r.Header.Get(h.traceCtxHeader); h.traceCtxHeader != "" && h.decodeTraceCtx != nil && value != "" {
+		if sc, ok := h.decodeTraceCtx(value); ok {
+			if trace, ok := sc.sentryTrace(); ok {
+				return sentry.ContinueFromHeaders(trace, r.Header.Get(sentry.SentryBaggageHeader))
+			}
+		}
+	}
+
+	if h.traceparent {
+		if trace, ok := sentryTraceFromTraceparent(r.Header.Get(traceparentHeader)); ok {
+			return sentry.ContinueFromHeaders(trace, r.Header.Get(sentry.SentryBaggageHeader))
+		}
+	}
+
+	return sentry.ContinueFromRequest(r)
+}
+
+// finishSpan finishes the transaction, unless BeforeSpanFinish drops it.
+// It may be called twice for upgrade requests, the hooks are only called the first time.
+func (h *handler) finishSpan(c *gin.Context, span *sentry.Span) {
+	if !span.EndTime.IsZero() {
+		return
+	}
+
+	if h.afterRequest != nil {
+		h.afterRequest(c, span)
+	}
+	if h.finalName != nil {
+		if name := h.finalName(c); name != "" {
+			span.Name = name
+			span.Source = sentry.SourceCustom
+			if h.source != "" {
+				span.Source = h.source
+			}
+		}
+	}
+	if h.beforeFinish != nil && !h.beforeFinish(c, span) {
+		span.Sampled = sentry.SampledFalse
+	}
+
+	span.Finish()
+}
+
+// nextWithSpan runs the handlers chain within a gin.handler child span of the transaction.
+func (h *handler) nextWithSpan(c *gin.Context, transaction *sentry.Span) {
+	span := transaction.StartChild("gin.handler")
+	span.Description = c.HandlerName()
+	// The status is overwritten once the handlers chain returns, as in the transaction.
+	span.Status = sentry.SpanStatusInternalError
 	defer span.Finish()
 
-	c.Request = c.Request.WithContext(span.Context())
-	hub.Scope().SetRequest(c.Request)
+	r := c.Request
+	c.Request = r.WithContext(span.Context())
+	defer func() {
+		c.Request = c.Request.WithContext(r.Context())
+	}()
+
+	c.Next()
+
+	span.Status = sentry.HTTPtoSpanStatus(c.Writer.Status())
+}
+
+// transactionName returns the name of the transaction together with its source.
+func (h *handler) transactionName(c *gin.Context) (string, sentry.TransactionSource) {
+	if h.name != nil {
+		if name := h.name(c); name != "" {
+			return name, sentry.SourceCustom
+		}
+	}
+
+	if h.nameHeader != "" {
+		if name := strings.TrimSpace(c.GetHeader(h.nameHeader)); name != "" && len(name) <= maxNameHeaderLength && h.allowedName(name) {
+			return c.Request.Method + " " + name, sentry.SourceCustom
+		}
+	}
+
+	// FullPath is resolved by the router before the handlers chain is invoked,
+	// so the matched route template is already known at this point.
+	if path := c.FullPath(); path != "" {
+		return c.Request.Method + " " + path, sentry.SourceRoute
+	}
+
+	if h.unmatchedName != "" && !h.pathNames {
+		return c.Request.Method + " " + h.unmatchedName, sentry.SourceCustom
+	}
+
+	if h.normalizePath != nil {
+		return c.Request.Method + " " + h.normalizePath(c.Request.URL.Path), sentry.SourceCustom
+	}
+
+	return c.Request.Method + " " + c.Request.URL.Path, sentry.SourceURL
+}
+
+// allowedName reports whether name is one of AllowedTransactionNames, any name is allowed if there are none.
+func (h *handler) allowedName(name string) bool {
+	if h.allowedNames == nil {
+		return true
+	}
+
+	_, ok := h.allowedNames[name]
+	return ok
+}
 
-	defer h.recoverWithSentry(hub, c.Request)
+// sample calls the TransactionSampler, its decisions are cached per route if SamplerCacheSize is set.
+func (h *handler) sample(c *gin.Context) (float64, bool) {
+	route := c.FullPath()
+	if h.samplerCache == nil || route == "" {
+		return h.sampler(c)
+	}
 
+	key := samplerCacheKey{method: c.Request.Method, route: route}
+	if decision, ok := h.samplerCache.get(key); ok {
+		return decision.rate, decision.ok
+	}
+
+	rate, ok := h.sampler(c)
+	h.samplerCache.add(samplerDecision{key: key, rate: rate, ok: ok})
+
+	return rate, ok
+}
+
+// sampleRate returns a span option making an explicit sampling decision with the given rate.
+func sampleRate(rate float64) sentry.SpanOption {
+	return func(s *sentry.Span) {
+		if rate > 0 && rand.Float64() < rate {
+			s.Sampled = sentry.SampledTrue
+			return
+		}
+		s.Sampled = sentry.SampledFalse
+	}
+}
+
+// spanURL returns the request URL reported in the span data.
+func (h *handler) spanURL(r *http.Request) string {
+	if h.keepURLQuery || (r.URL.RawQuery == "" && r.URL.Fragment == "") {
+		return r.URL.String()
+	}
+
+	u := *r.URL
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String()
+}
+
+// setParamsData attaches the route parameters to the span, the router binds them before the handlers chain is invoked.
+func (h *handler) setParamsData(span *sentry.Span, params gin.Params) {
+	for _, p := range params {
+		value := p.Value
+		if _, ok := h.scrubParams[p.Key]; ok {
+			value = filteredValue
+		}
+		setSpanData(span, "params."+p.Key, value)
+	}
+}
+
+// setPayloadSizesData records the sizes of the request and response bodies,
+// gin.ResponseWriter already counts the bytes written, so the writer doesn't have to be wrapped.
+func setPayloadSizesData(span *sentry.Span, c *gin.Context) {
+	if c.Request.ContentLength >= 0 {
+		setSpanData(span, "http.request_content_length", c.Request.ContentLength)
+	}
+
+	size := c.Writer.Size()
+	if size < 0 {
+		size = 0
+	}
+	setSpanData(span, "http.response_content_length", size)
+}
+
+// queryData returns the query parameters of the request with the values of sensitive parameters scrubbed.
+// The query is parsed into a new map, the URL of the request is left untouched.
+func (h *handler) queryData(r *http.Request) map[string][]string {
+	query := r.URL.Query()
+	for k, values := range query {
+		if _, ok := h.scrubQuery[strings.ToLower(k)]; !ok {
+			continue
+		}
+		for i := range values {
+			values[i] = filteredValue
+		}
+	}
+
+	return query
+}
+
+// setSpanData sets the data on the span, initializing the data map if needed.
+func setSpanData(span *sentry.Span, key string, value interface{}) {
+	if span.Data == nil {
+		span.Data = make(map[string]interface{})
+	}
+	span.Data[key] = value
+}
+
+// setTraceHeaders sets the sentry-trace and baggage headers on the response,
+// a baggage header that is already present is left untouched.
+func setTraceHeaders(c *gin.Context, span *sentry.Span) {
+	c.Header("sentry-trace", span.ToSentryTrace())
+
+	if c.Writer.Header().Get("baggage") != "" {
+		return
+	}
+	if baggage := span.ToBaggage(); baggage != "" {
+		c.Header("baggage", baggage)
+	}
+}
+
+// setRequest replaces the context of the request and binds the request to the scope of the hub.
+func (h *handler) setRequest(c *gin.Context, hub *sentry.Hub, ctx context.Context) {
+	if h.logger != nil {
+		ctx = withLogger(c, ctx, h.logger, sentry.SpanFromContext(ctx))
+	}
+	c.Request = c.Request.WithContext(ctx)
+
+	if r := scrubRequest(c.Request, h.scrubHeaders); r != c.Request {
+		hub.Scope().SetRequest(r)
+		// SetRequest wraps the body to buffer it lazily, the handlers have to read from the wrapped body.
+		c.Request.Body = r.Body
+	} else {
+		hub.Scope().SetRequest(c.Request)
+	}
+
+	if h.captureBody {
+		captureRequestBody(c, hub.Scope(), h.bodyLimit)
+	}
+	if h.formFieldNames {
+		captureFormFieldNames(c, hub.Scope())
+	}
+}
+
+// skipTracing reports whether no transaction should be started for the request.
+func (h *handler) skipTracing(c *gin.Context) bool {
+	return h.disableTracing ||
+		h.ignorePaths.match(c.Request.URL.Path) ||
+		h.ignorePaths.match(c.FullPath()) ||
+		(h.wsMode == WebSocketSkip && isWebSocketUpgrade(c.Request)) ||
+		h.skipMethod(c.Request.Method) ||
+		h.isStaticAsset(c.Request.URL.Path)
+}
+
+// skipMethod reports whether method is one of SkipMethods. The list is expected to be short,
+// so it's scanned rather than indexed, which avoids normalizing the method of every request.
+func (h *handler) skipMethod(method string) bool {
+	for _, m := range h.skipMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isStaticAsset reports whether the extension of p is one of StaticExtensions, if SkipStaticAssets is set.
+func (h *handler) isStaticAsset(p string) bool {
+	if h.staticExts == nil {
+		return false
+	}
+
+	ext := path.Ext(p)
+	if ext == "" {
+		return false
+	}
+
+	_, ok := h.staticExts[strings.ToLower(ext)]
+	return ok
+}
+
+// handleUntraced runs the handlers chain without starting a transaction, panics are still recovered.
+func (h *handler) handleUntraced(c *gin.Context, hub *sentry.Hub, ctx context.Context) {
+	h.setRequest(c, hub, ctx)
+
+	defer h.recoverWithSentry(hub, c)
+
+	lastEventID := hub.LastEventID()
 	c.Next()
+
+	h.report(hub, c, lastEventID)
+}
+
+// report reports what went wrong while handling the request once the handlers chain returns,
+// lastEventID is the ID of the last event captured by the hub before the chain was invoked.
+func (h *handler) report(hub *sentry.Hub, c *gin.Context, lastEventID sentry.EventID) {
+	if len(h.respHeaderCtx) > 0 {
+		if hc := headersContext(c.Writer.Header(), h.respHeaderCtx); hc != nil {
+			hub.Scope().SetContext("response_headers", hc)
+		}
+	}
+
+	h.reportErrors(hub, c)
+	if h.errorBreadcrumbs {
+		h.addErrorBreadcrumbs(hub, c)
+	}
+	h.reportStatus(hub, c)
+
+	if h.captureCtxErrors && hub.LastEventID() == lastEventID {
+		h.reportContextError(hub, c)
+	}
+}
+
+// reportErrors reports errors collected in c.Errors, the scope already carries the request
+// and the trace context, so the events are correlated with the transaction.
+func (h *handler) reportErrors(hub *sentry.Hub, c *gin.Context) {
+	if !h.captureErrors {
+		return
+	}
+
+	for _, err := range c.Errors.ByType(h.errorTypes) {
+		dropStack := h.dropStacktrace && sentry.ExtractStacktrace(err.Err) == nil
+		var validation sentry.Context
+		if h.validationErrors {
+			validation = validationContext(err.Err)
+		}
+		if !dropStack && h.errorLevel == nil && validation == nil {
+			h.captured(hub.CaptureException(err.Err))
+			continue
+		}
+
+		hub.WithScope(func(scope *sentry.Scope) {
+			if dropStack {
+				scope.AddEventProcessor(dropStacktrace)
+			}
+			if h.errorLevel != nil {
+				scope.SetLevel(h.errorLevel(err.Err))
+			}
+			if validation != nil {
+				scope.SetContext("validation", validation)
+			}
+			h.captured(hub.CaptureException(err.Err))
+		})
+	}
 }
 
-func (h *handler) recoverWithSentry(hub *sentry.Hub, r *http.Request) {
+// addErrorBreadcrumbs adds a breadcrumb for every error collected in c.Errors. They're added once the errors
+// have been reported, so the events of the errors don't repeat them.
+func (h *handler) addErrorBreadcrumbs(hub *sentry.Hub, c *gin.Context) {
+	for _, err := range c.Errors {
+		h.addBreadcrumb(c, hub, &sentry.Breadcrumb{
+			Type:      "error",
+			Category:  "gin.error",
+			Message:   err.Error(),
+			Level:     sentry.LevelError,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// dropStacktrace removes the stack trace the SDK attaches to the most recent error in the chain,
+// which otherwise points to the middleware rather than to the origin of the error.
+func dropStacktrace(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+	if n := len(event.Exception); n > 0 {
+		event.Exception[n-1].Stacktrace = nil
+	}
+
+	return event
+}
+
+// reportStatus reports the response status if CaptureResponseErrors matches it.
+func (h *handler) reportStatus(hub *sentry.Hub, c *gin.Context) {
+	if h.captureStatus == nil || c.GetBool(panicReportedKey) {
+		return
+	}
+
+	status := c.Writer.Status()
+	if !h.captureStatus(status) {
+		return
+	}
+
+	h.captured(hub.CaptureMessage(fmt.Sprintf("%s %s responded with status %d", c.Request.Method, routeOf(c), status)))
+}
+
+// reportContextError reports the request context being canceled or past its deadline.
+func (h *handler) reportContextError(hub *sentry.Hub, c *gin.Context) {
+	err := c.Request.Context().Err()
+	if err == nil {
+		return
+	}
+
+	level := sentry.LevelError
+	if errors.Is(err, context.Canceled) {
+		level = sentry.LevelWarning
+	}
+
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetLevel(level)
+		h.captured(hub.CaptureMessage(fmt.Sprintf("%s %s: %s", c.Request.Method, routeOf(c), err)))
+	})
+}
+
+// captured calls the OnEventCaptured callback if an event has been captured.
+func (h *handler) captured(eventID *sentry.EventID) {
+	if eventID != nil && h.onCaptured != nil {
+		h.onCaptured(eventID)
+	}
+}
+
+// routeOf returns the matched route template, or the raw path if no route matched.
+func routeOf(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+
+	return c.Request.URL.Path
+}
+
+func (h *handler) recoverWithSentry(hub *sentry.Hub, c *gin.Context) {
 	if err := recover(); err != nil {
-		eventID := hub.RecoverWithContext(
-			context.WithValue(r.Context(), sentry.RequestContextKey, r),
-			err,
-		)
-		if eventID != nil && h.waitForDelivery {
-			hub.Flush(h.timeout)
-		}
-		if h.repanic {
+		if h.skipRecover(err) {
+			panic(err)
+		}
+
+		var eventID *sentry.EventID
+		if !h.deduplicate || !c.GetBool(panicReportedKey) {
+			eventID = h.reportPanic(hub, c, err)
+		}
+		if h.onRecover != nil {
+			h.onRecover(c, err, eventID)
+		}
+		if h.repanic && !c.GetBool(repanicDisabledKey) {
 			panic(err)
 		}
+		if h.abort {
+			abortWithInternalError(c)
+		}
+	}
+}
+
+// reportPanic reports the recovered panic and, if WaitForDelivery is true, waits for its delivery.
+func (h *handler) reportPanic(hub *sentry.Hub, c *gin.Context, err interface{}) *sentry.EventID {
+	r := c.Request
+	if h.goroutineDump {
+		// The dump is attached to a pushed scope, so it's not attached to the transaction as well.
+		hub.PushScope()
+		defer hub.PopScope()
+		hub.Scope().AddAttachment(goroutineDump())
+	}
+
+	reporter := h.reporter(hub)
+	if route := c.FullPath(); h.fingerprint && route != "" {
+		reporter.Scope().SetFingerprint([]string{"{{ default }}", route})
+	}
+	if h.levelForPanic != nil {
+		reporter.Scope().SetLevel(h.levelForPanic(err))
+	}
+	if h.beforeCapture != nil {
+		h.callBeforeCapture(c, hub, err)
+	}
+
+	reported := err
+	if h.panicToError != nil {
+		if converted := h.panicToError(err); converted != nil {
+			reported = converted
+		}
+	}
+
+	eventID := reporter.RecoverWithContext(
+		context.WithValue(r.Context(), sentry.RequestContextKey, r),
+		reported,
+	)
+	if eventID == nil {
+		return nil
+	}
+
+	c.Set(panicReportedKey, true)
+	h.captured(eventID)
+
+	if (h.waitForDelivery || c.GetBool(forceWaitKey)) && h.breaker.allow() {
+		if timeout := h.flushTimeout(r.Context()); timeout > 0 {
+			h.breaker.record(reporter.Flush(timeout))
+		}
+	}
+
+	return eventID
+}
+
+// goroutineDump returns an attachment with the stacks of all goroutines, truncated to maxGoroutineDumpSize.
+func goroutineDump() *sentry.Attachment {
+	buf := make([]byte, maxGoroutineDumpSize)
+	n := runtime.Stack(buf, true)
+
+	return &sentry.Attachment{
+		Filename:    "goroutines.txt",
+		ContentType: "text/plain",
+		Payload:     buf[:n],
+	}
+}
+
+func isErrAbortHandler(recovered interface{}) bool {
+	err, ok := recovered.(error)
+	return ok && errors.Is(err, http.ErrAbortHandler)
+}
+
+// reporter returns the hub used to report panics.
+func (h *handler) reporter(hub *sentry.Hub) sentryHub {
+	if h.wrapHub != nil {
+		return h.wrapHub(hub)
+	}
+
+	return hub
+}
+
+// abortWithInternalError aborts the request with 500 Internal Server Error,
+// the status isn't changed if the response has already been written.
+func abortWithInternalError(c *gin.Context) {
+	if c.Writer.Written() {
+		c.Abort()
+		return
+	}
+
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+// flushTimeout returns the timeout for the delivery of panic events of the request,
+// the greater of Timeout and the override set with WithFlushTimeout, capped at the deadline
+// of ctx if RespectContextDeadline is true.
+func (h *handler) flushTimeout(ctx context.Context) time.Duration {
+	timeout := h.timeout
+	if d, ok := ctx.Value(flushTimeoutKey{}).(time.Duration); ok && d > timeout {
+		timeout = d
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && h.respectDeadline {
+		if untilDeadline := time.Until(deadline); untilDeadline < timeout {
+			timeout = untilDeadline
+		}
 	}
+
+	return timeout
+}
+
+// callBeforeCapture calls the BeforeCapture hook, making sure that its panic doesn't interrupt the recovery.
+func (h *handler) callBeforeCapture(c *gin.Context, hub *sentry.Hub, recovered interface{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			sentry.Logger.Printf("sentrygin: BeforeCapture panicked: %v", err)
+		}
+	}()
+
+	h.beforeCapture(c, hub, recovered)
+}
+
+// promoteContextKeys sets the values of keys stored in c as tags of span, see PromoteContextKeys.
+func promoteContextKeys(span *sentry.Span, c *gin.Context, keys []string) {
+	if len(keys) > maxPromotedKeys {
+		keys = keys[:maxPromotedKeys]
+	}
+
+	for _, key := range keys {
+		v, ok := c.Get(key)
+		if !ok {
+			continue
+		}
+		if value, ok := tagValue(v); ok {
+			span.SetTag(key, value)
+		}
+	}
+}
+
+// tagValue stringifies scalar values, it returns false for other values and for values too long to be tags.
+func tagValue(v interface{}) (string, bool) {
+	var value string
+	switch v := v.(type) {
+	case string:
+		value = v
+	case bool:
+		value = strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		value = fmt.Sprint(v)
+	default:
+		return "", false
+	}
+
+	return value, value != "" && len(value) <= maxTagValueLength
+}
+
+// mediaType returns the lowercased media type of a Content-Type header value, without its parameters.
+func mediaType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// GetHubFromContext returns the request-scoped *sentry.Hub set up by the middleware,
+// it falls back to sentry.CurrentHub() if there's none.
+func GetHubFromContext(c *gin.Context) *sentry.Hub {
+	if hub, ok := c.Value(HubKey).(*sentry.Hub); ok {
+		return hub
+	}
+	if c.Request != nil {
+		if hub := sentry.GetHubFromContext(c.Request.Context()); hub != nil {
+			return hub
+		}
+	}
+
+	return sentry.CurrentHub()
+}
+
+// SpanFromContext returns the span active in the request context, which is the transaction started by the middleware
+// unless a handler replaced the request context with one carrying a child span. It returns nil if there's none,
+// e.g. because the request is not traced. A child span can be started with:
+//
+//	if span := sentrygin.SpanFromContext(c); span != nil {
+//		child := span.StartChild("db.query")
+//		defer child.Finish()
+//	}
+func SpanFromContext(c *gin.Context) *sentry.Span {
+	if c.Request == nil {
+		return nil
+	}
+
+	return sentry.SpanFromContext(c.Request.Context())
+}
+
+// Flush waits until the events buffered by sentry.CurrentHub() are sent to Sentry or the timeout is reached,
+// it returns false in the latter case.
+//
+// It is meant to be called in the shutdown path of the server, after srv.Shutdown returns, so the events
+// of the requests that were in flight during shutdown are delivered as well.
+func Flush(timeout time.Duration) bool {
+	return sentry.CurrentHub().Flush(timeout)
+}
+
+// RecoverGoroutine recovers from a panic in a goroutine spawned by a handler and reports it
+// using the hub of the request, it has to be deferred at the top of the goroutine:
+//
+//	cCp := c.Copy()
+//	go func() {
+//		defer sentrygin.RecoverGoroutine(cCp, false)
+//		// ...
+//	}()
+//
+// As with any goroutine outliving the handler, it should be given a copy of the context obtained with c.Copy().
+// If repanic is true, the panic is propagated once it has been reported.
+func RecoverGoroutine(c *gin.Context, repanic bool) {
+	err := recover()
+	if err == nil {
+		return
+	}
+
+	hub := GetHubFromContext(c)
+	ctx := context.Background()
+	if r := c.Request; r != nil {
+		ctx = context.WithValue(r.Context(), sentry.RequestContextKey, r)
+	}
+	hub.RecoverWithContext(ctx, err)
+
+	if repanic {
+		panic(err)
+	}
+}
+
+// DisableRepanic disables Repanic for the request, so its panics are swallowed once they have been reported,
+// e.g. for a handler rendering its own response from a deferred function. The other requests are not affected.
+// It has to be called before the panic, AbortWithInternalError still applies.
+func DisableRepanic(c *gin.Context) {
+	c.Set(repanicDisabledKey, true)
+}
+
+// ForceWaitForDelivery makes the middleware wait for the delivery of the panic event of the request,
+// as if WaitForDelivery was set, e.g. for a handler whose process may be killed right after a panic.
+// The timeout can be overridden with WithFlushTimeout and FlushCircuitBreaker still applies.
+func ForceWaitForDelivery(c *gin.Context) {
+	c.Set(forceWaitKey, true)
+}
+
+type flushTimeoutKey struct{}
+
+// WithFlushTimeout returns a copy of ctx overriding the timeout for the delivery of panic events
+// when WaitForDelivery is true. The override only takes effect if it's greater than the configured Timeout.
+//
+//	c.Request = c.Request.WithContext(sentrygin.WithFlushTimeout(c.Request.Context(), 10*time.Second))
+func WithFlushTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, flushTimeoutKey{}, d)
 }
@@ -0,0 +1,3302 @@
+package sentrygin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Kichiyaki/sentrygin/testtransport"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	pkgerrors "github.com/pkg/errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestHub returns a hub reporting to an in-memory transport, with every transaction sampled.
+func newTestHub(t testing.TB) (*sentry.Hub, *testtransport.Transport) {
+	t.Helper()
+
+	hub, transport, err := testtransport.NewHub(sentry.ClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hub, transport
+}
+
+// newRouter returns a router using the middleware configured with opts, reporting to the returned transport.
+func newRouter(t testing.TB, opts Options) (*gin.Engine, *testtransport.Transport) {
+	t.Helper()
+
+	hub, transport := newTestHub(t)
+	r := gin.New()
+	r.Use(NewWithClient(hub.Client(), opts))
+
+	return r, transport
+}
+
+// serve serves a request with the given method and target, the request context carries no hub.
+func serve(r http.Handler, method, target string) *httptest.ResponseRecorder {
+	return serveRequest(r, httptest.NewRequest(method, target, nil))
+}
+
+func serveRequest(r http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// onlyTransaction returns the only transaction sent to transport, it fails the test if there's not exactly one.
+func onlyTransaction(t *testing.T, transport *testtransport.Transport) *sentry.Event {
+	t.Helper()
+
+	transactions := transport.Transactions()
+	if len(transactions) != 1 {
+		t.Fatalf("got %d transactions, want 1", len(transactions))
+	}
+
+	return transactions[0]
+}
+
+// onlyEvent returns the only event sent to transport, it fails the test if there's not exactly one.
+func onlyEvent(t *testing.T, transport *testtransport.Transport) *sentry.Event {
+	t.Helper()
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+
+	return events[0]
+}
+
+func TestTransactionName(t *testing.T) {
+	tests := []struct {
+		name     string
+		callback func(c *gin.Context) string
+		want     string
+		source   sentry.TransactionSource
+	}{
+		{
+			name: "override",
+			callback: func(c *gin.Context) string {
+				return "v2:" + c.Request.Method + " " + c.FullPath()
+			},
+			want:   "v2:GET /users/:id",
+			source: sentry.SourceCustom,
+		},
+		{
+			name: "fallback",
+			callback: func(c *gin.Context) string {
+				return ""
+			},
+			want:   "GET /users/:id",
+			source: sentry.SourceRoute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{TransactionName: tt.callback})
+			r.GET("/users/:id", func(c *gin.Context) {})
+
+			serve(r, http.MethodGet, "/users/1")
+
+			transaction := onlyTransaction(t, transport)
+			if transaction.Transaction != tt.want {
+				t.Errorf("transaction = %q, want %q", transaction.Transaction, tt.want)
+			}
+			if transaction.TransactionInfo == nil || transaction.TransactionInfo.Source != tt.source {
+				t.Errorf("transaction info = %+v, want source %q", transaction.TransactionInfo, tt.source)
+			}
+		})
+	}
+}
+
+func TestDefaultTags(t *testing.T) {
+	r, transport := newRouter(t, Options{
+		CaptureErrors: true,
+		DefaultTags:   map[string]string{"region": "eu-west-1"},
+	})
+	r.GET("/tag", func(c *gin.Context) {
+		GetHubFromContext(c).Scope().SetTag("request", "tagged")
+		_ = c.Error(errors.New("tagged"))
+	})
+	r.GET("/plain", func(c *gin.Context) {
+		_ = c.Error(errors.New("plain"))
+	})
+
+	serve(r, http.MethodGet, "/tag")
+	serve(r, http.MethodGet, "/plain")
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for _, event := range append(events, transport.Transactions()...) {
+		if got := event.Tags["region"]; got != "eu-west-1" {
+			t.Errorf("region tag of %q = %q, want eu-west-1", event.Message+event.Transaction, got)
+		}
+	}
+	if got, ok := events[1].Tags["request"]; ok {
+		t.Errorf("request tag leaked into the next request: %q", got)
+	}
+}
+
+// benchmarkRequests serves b.N requests to a route of a router using the middleware configured with opts.
+func benchmarkRequests(b *testing.B, opts Options) {
+	r, transport := newRouter(b, opts)
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		transport.Reset()
+	}
+}
+
+func BenchmarkDefaultTags(b *testing.B) {
+	b.Run("none", func(b *testing.B) {
+		benchmarkRequests(b, Options{})
+	})
+	b.Run("five", func(b *testing.B) {
+		benchmarkRequests(b, Options{
+			DefaultTags: map[string]string{
+				"region":  "eu-west-1",
+				"cluster": "main",
+				"service": "api",
+				"team":    "core",
+				"tier":    "web",
+			},
+		})
+	})
+}
+
+func TestSpanHTTPData(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		url  string
+	}{
+		{
+			name: "query stripped",
+			url:  "/users/1",
+		},
+		{
+			name: "query kept",
+			opts: Options{KeepURLQuery: true},
+			url:  "/users/1?token=secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/users/:id", func(c *gin.Context) {
+				c.Status(http.StatusAccepted)
+			})
+
+			serve(r, http.MethodGet, "/users/1?token=secret")
+
+			data := onlyTransaction(t, transport).Extra
+			if got := data["http.request.method"]; got != http.MethodGet {
+				t.Errorf("http.request.method = %v, want GET", got)
+			}
+			if got := data["http.url"]; got != tt.url {
+				t.Errorf("http.url = %v, want %s", got, tt.url)
+			}
+			if got := data["http.response.status_code"]; got != http.StatusAccepted {
+				t.Errorf("http.response.status_code = %v, want %d", got, http.StatusAccepted)
+			}
+		})
+	}
+}
+
+// bindCurrentHub binds a client reporting to the returned transport to sentry.CurrentHub()
+// until the end of the test, for the middleware created with New to report to it.
+func bindCurrentHub(t testing.TB) *testtransport.Transport {
+	t.Helper()
+
+	hub, transport := newTestHub(t)
+	previous := sentry.CurrentHub().Client()
+	sentry.CurrentHub().BindClient(hub.Client())
+	t.Cleanup(func() {
+		sentry.CurrentHub().BindClient(previous)
+	})
+
+	return transport
+}
+
+// unbindCurrentHub unbinds the client of sentry.CurrentHub() for the duration of the test,
+// as if sentry.Init hadn't been called.
+func unbindCurrentHub(t testing.TB) {
+	t.Helper()
+
+	previous := sentry.CurrentHub().Client()
+	sentry.CurrentHub().BindClient(nil)
+	t.Cleanup(func() {
+		sentry.CurrentHub().BindClient(previous)
+	})
+}
+
+func BenchmarkReuseHub(b *testing.B) {
+	for _, reuse := range []bool{false, true} {
+		name := "clone"
+		if reuse {
+			name = "reuse"
+		}
+		b.Run(name, func(b *testing.B) {
+			transport := bindCurrentHub(b)
+			r := gin.New()
+			r.Use(New(Options{ReuseHub: reuse}))
+			r.GET("/users/:id", func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.ServeHTTP(httptest.NewRecorder(), req)
+				transport.Reset()
+			}
+		})
+	}
+}
+
+func TestReuseHub(t *testing.T) {
+	transport := bindCurrentHub(t)
+	r := gin.New()
+	r.Use(New(Options{ReuseHub: true}))
+	r.GET("/", func(c *gin.Context) {
+		if hub := GetHubFromContext(c); hub != sentry.CurrentHub() {
+			t.Error("the current hub isn't reused")
+		}
+		sentry.CurrentHub().Scope().SetTag("request", "tagged")
+	})
+
+	serve(r, http.MethodGet, "/")
+
+	if got := onlyTransaction(t, transport).Tags["request"]; got != "tagged" {
+		t.Errorf("request tag = %q, want tagged", got)
+	}
+	sentry.CurrentHub().CaptureMessage("after")
+	if got, ok := onlyEvent(t, transport).Tags["request"]; ok {
+		t.Errorf("the pushed scope hasn't been popped, request tag = %q", got)
+	}
+}
+
+func TestGetHubFromContext(t *testing.T) {
+	t.Run("middleware", func(t *testing.T) {
+		r, _ := newRouter(t, Options{})
+		r.GET("/", func(c *gin.Context) {
+			hub := GetHubFromContext(c)
+			if hub == sentry.CurrentHub() {
+				t.Error("got the current hub, want the hub of the request")
+			}
+			if stored, _ := c.Get(HubKey); stored != hub {
+				t.Errorf("hub stored under HubKey = %v, want %v", stored, hub)
+			}
+			if fromRequest := sentry.GetHubFromContext(c.Request.Context()); fromRequest != hub {
+				t.Errorf("hub of the request context = %v, want %v", fromRequest, hub)
+			}
+		})
+
+		serve(r, http.MethodGet, "/")
+	})
+
+	t.Run("request context", func(t *testing.T) {
+		hub, _ := newTestHub(t)
+		r := gin.New()
+		r.GET("/", func(c *gin.Context) {
+			if got := GetHubFromContext(c); got != hub {
+				t.Errorf("hub = %v, want the hub of the request context", got)
+			}
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		serveRequest(r, req.WithContext(sentry.SetHubOnContext(req.Context(), hub)))
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		r := gin.New()
+		r.GET("/", func(c *gin.Context) {
+			if got := GetHubFromContext(c); got != sentry.CurrentHub() {
+				t.Errorf("hub = %v, want the current hub", got)
+			}
+		})
+
+		serve(r, http.MethodGet, "/")
+	})
+}
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		want      string
+	}{
+		{name: "default", want: "http.server"},
+		{name: "custom", operation: "http.gateway", want: "http.gateway"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{Operation: tt.operation})
+			r.GET("/", func(c *gin.Context) {})
+
+			serve(r, http.MethodGet, "/")
+
+			if got := onlyTransaction(t, transport).Contexts["trace"]["op"]; got != tt.want {
+				t.Errorf("op = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttachRouteParams(t *testing.T) {
+	r, transport := newRouter(t, Options{
+		AttachRouteParams: true,
+		ScrubParams:       []string{"token"},
+	})
+	r.GET("/orders/:orderID/items/:itemID/:token", func(c *gin.Context) {})
+
+	serve(r, http.MethodGet, "/orders/42/items/7/secret")
+
+	transaction := onlyTransaction(t, transport)
+	want := map[string]interface{}{
+		"params.orderID": "42",
+		"params.itemID":  "7",
+		"params.token":   filteredValue,
+	}
+	for key, value := range want {
+		if got := transaction.Extra[key]; got != value {
+			t.Errorf("%s = %v, want %v", key, got, value)
+		}
+	}
+	if transaction.Transaction != "GET /orders/:orderID/items/:itemID/:token" {
+		t.Errorf("transaction = %q, the params mustn't be part of the name", transaction.Transaction)
+	}
+}
+
+func TestRecoverGoroutine(t *testing.T) {
+	r, transport := newRouter(t, Options{})
+	r.GET("/work", func(c *gin.Context) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		cCp := c.Copy()
+		go func() {
+			defer wg.Done()
+			defer RecoverGoroutine(cCp, false)
+			panic("background work failed")
+		}()
+		wg.Wait()
+	})
+
+	serve(r, http.MethodGet, "/work")
+
+	event := onlyEvent(t, transport)
+	if event.Message != "background work failed" {
+		t.Errorf("message = %q, want the panic value", event.Message)
+	}
+	if event.Request == nil || !strings.HasSuffix(event.Request.URL, "/work") {
+		t.Errorf("request = %+v, want the request of the handler", event.Request)
+	}
+}
+
+func TestRecoverGoroutineRepanic(t *testing.T) {
+	r, transport := newRouter(t, Options{})
+	r.GET("/work", func(c *gin.Context) {
+		var recovered interface{}
+		func() {
+			defer func() {
+				recovered = recover()
+			}()
+			defer RecoverGoroutine(c.Copy(), true)
+			panic("background work failed")
+		}()
+		if recovered != "background work failed" {
+			t.Errorf("recovered %v, want the panic to be propagated", recovered)
+		}
+	})
+
+	serve(r, http.MethodGet, "/work")
+
+	onlyEvent(t, transport)
+}
+
+func TestFingerprintByRoute(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{name: "default"},
+		{
+			name: "by route",
+			opts: Options{FingerprintByRoute: true},
+			want: []string{"{{ default }}", "/users/:id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/users/:id", func(c *gin.Context) {
+				panic("boom")
+			})
+
+			serve(r, http.MethodGet, "/users/1")
+
+			if got := onlyEvent(t, transport).Fingerprint; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fingerprint = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddRequestBreadcrumb(t *testing.T) {
+	r, transport := newRouter(t, Options{AddRequestBreadcrumb: true})
+	r.POST("/users", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	serve(r, http.MethodPost, "/users?token=secret")
+
+	breadcrumbs := onlyEvent(t, transport).Breadcrumbs
+	if len(breadcrumbs) != 1 {
+		t.Fatalf("got %d breadcrumbs, want 1", len(breadcrumbs))
+	}
+	breadcrumb := breadcrumbs[0]
+	if breadcrumb.Category != "http" {
+		t.Errorf("category = %q, want http", breadcrumb.Category)
+	}
+	want := map[string]interface{}{"method": http.MethodPost, "url": "/users"}
+	if !reflect.DeepEqual(breadcrumb.Data, want) {
+		t.Errorf("data = %v, want %v", breadcrumb.Data, want)
+	}
+}
+
+type domainPanic struct {
+	reason string
+}
+
+func TestLevelForPanic(t *testing.T) {
+	levelForPanic := func(recovered interface{}) sentry.Level {
+		if _, ok := recovered.(*domainPanic); ok {
+			return sentry.LevelWarning
+		}
+		return sentry.LevelFatal
+	}
+	tests := []struct {
+		name      string
+		opts      Options
+		recovered interface{}
+		want      sentry.Level
+	}{
+		{name: "default", recovered: &domainPanic{reason: "retry"}, want: sentry.LevelFatal},
+		{
+			name:      "downgraded",
+			opts:      Options{LevelForPanic: levelForPanic},
+			recovered: &domainPanic{reason: "retry"},
+			want:      sentry.LevelWarning,
+		},
+		{
+			name:      "other panic",
+			opts:      Options{LevelForPanic: levelForPanic},
+			recovered: "boom",
+			want:      sentry.LevelFatal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/", func(c *gin.Context) {
+				panic(tt.recovered)
+			})
+
+			serve(r, http.MethodGet, "/")
+
+			if got := onlyEvent(t, transport).Level; got != tt.want {
+				t.Errorf("level = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeHub wraps the hub used to report panics (see handler.wrapHub), it records the timeouts of the flushes
+// rather than waiting for the delivery of the events.
+type fakeHub struct {
+	*sentry.Hub
+	delivered bool
+
+	mu      sync.Mutex
+	flushes []time.Duration
+}
+
+func (f *fakeHub) Flush(timeout time.Duration) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.flushes = append(f.flushes, timeout)
+	return f.delivered
+}
+
+func (f *fakeHub) flushTimeouts() []time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]time.Duration(nil), f.flushes...)
+}
+
+// newFakeHubRouter is like newRouter, but reports panics through fake.
+func newFakeHubRouter(t *testing.T, opts Options, fake *fakeHub) (*gin.Engine, *testtransport.Transport) {
+	t.Helper()
+
+	hub, transport := newTestHub(t)
+	h := newHandler(opts, &handlerState{})
+	h.client = hub.Client()
+	h.withClient = true
+	h.wrapHub = func(hub *sentry.Hub) sentryHub {
+		fake.Hub = hub
+		return fake
+	}
+	r := gin.New()
+	r.Use(h.handle)
+
+	return r, transport
+}
+
+func TestWaitForDelivery(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		delivered bool
+		deadline  time.Duration
+		requests  int
+		check     func(t *testing.T, flushes []time.Duration)
+	}{
+		{
+			name:     "disabled",
+			requests: 1,
+			check: func(t *testing.T, flushes []time.Duration) {
+				if len(flushes) != 0 {
+					t.Errorf("flushes = %v, want none", flushes)
+				}
+			},
+		},
+		{
+			name:      "timeout",
+			opts:      Options{WaitForDelivery: true, Timeout: 3 * time.Second},
+			delivered: true,
+			requests:  2,
+			check: func(t *testing.T, flushes []time.Duration) {
+				if want := []time.Duration{3 * time.Second, 3 * time.Second}; !reflect.DeepEqual(flushes, want) {
+					t.Errorf("flushes = %v, want %v", flushes, want)
+				}
+			},
+		},
+		{
+			name:      "default timeout",
+			opts:      Options{WaitForDelivery: true},
+			delivered: true,
+			requests:  1,
+			check: func(t *testing.T, flushes []time.Duration) {
+				if want := []time.Duration{2 * time.Second}; !reflect.DeepEqual(flushes, want) {
+					t.Errorf("flushes = %v, want %v", flushes, want)
+				}
+			},
+		},
+		{
+			name:      "capped at the context deadline",
+			opts:      Options{WaitForDelivery: true, Timeout: time.Minute, RespectContextDeadline: true},
+			delivered: true,
+			deadline:  time.Second,
+			requests:  1,
+			check: func(t *testing.T, flushes []time.Duration) {
+				if len(flushes) != 1 || flushes[0] <= 0 || flushes[0] > time.Second {
+					t.Errorf("flushes = %v, want one flush of at most 1s", flushes)
+				}
+			},
+		},
+		{
+			name:      "context deadline ignored",
+			opts:      Options{WaitForDelivery: true, Timeout: time.Minute},
+			delivered: true,
+			deadline:  time.Second,
+			requests:  1,
+			check: func(t *testing.T, flushes []time.Duration) {
+				if want := []time.Duration{time.Minute}; !reflect.DeepEqual(flushes, want) {
+					t.Errorf("flushes = %v, want %v", flushes, want)
+				}
+			},
+		},
+		{
+			name: "circuit breaker",
+			opts: Options{
+				WaitForDelivery:     true,
+				FlushCircuitBreaker: FlushCircuitBreaker{Failures: 2, Cooldown: time.Hour},
+			},
+			requests: 4,
+			check: func(t *testing.T, flushes []time.Duration) {
+				if len(flushes) != 2 {
+					t.Errorf("got %d flushes, want 2 before the breaker opens", len(flushes))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeHub{delivered: tt.delivered}
+			r, transport := newFakeHubRouter(t, tt.opts, fake)
+			r.GET("/", func(c *gin.Context) {
+				panic("boom")
+			})
+
+			for i := 0; i < tt.requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				if tt.deadline > 0 {
+					ctx, cancel := context.WithTimeout(req.Context(), tt.deadline)
+					defer cancel()
+					req = req.WithContext(ctx)
+				}
+				serveRequest(r, req)
+			}
+
+			if got := len(transport.Events()); got != tt.requests {
+				t.Errorf("got %d events, want %d", got, tt.requests)
+			}
+			tt.check(t, fake.flushTimeouts())
+		})
+	}
+}
+
+func TestMinDuration(t *testing.T) {
+	r, transport := newRouter(t, Options{MinDuration: 20 * time.Millisecond})
+	r.GET("/fast", func(c *gin.Context) {})
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+	})
+
+	serve(r, http.MethodGet, "/fast")
+	serve(r, http.MethodGet, "/slow")
+
+	if got := onlyTransaction(t, transport).Transaction; got != "GET /slow" {
+		t.Errorf("transaction = %q, want GET /slow only", got)
+	}
+}
+
+func TestCaptureErrorsStacktrace(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		err       error
+		wantStack bool
+	}{
+		{
+			name:      "plain error",
+			opts:      Options{CaptureErrors: true},
+			err:       errors.New("plain"),
+			wantStack: true,
+		},
+		{
+			name: "plain error without stack trace",
+			opts: Options{CaptureErrors: true, DropStacktrace: true},
+			err:  errors.New("plain"),
+		},
+		{
+			name:      "error with stack trace",
+			opts:      Options{CaptureErrors: true, DropStacktrace: true},
+			err:       pkgerrors.New("with stack"),
+			wantStack: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/", func(c *gin.Context) {
+				_ = c.Error(tt.err)
+			})
+
+			serve(r, http.MethodGet, "/")
+
+			exceptions := onlyEvent(t, transport).Exception
+			if len(exceptions) == 0 {
+				t.Fatal("no exception reported")
+			}
+			if got := exceptions[len(exceptions)-1].Stacktrace != nil; got != tt.wantStack {
+				t.Errorf("stack trace reported = %t, want %t", got, tt.wantStack)
+			}
+		})
+	}
+}
+
+func getUser(c *gin.Context) {}
+
+func TestTagHandlerName(t *testing.T) {
+	r, transport := newRouter(t, Options{TagHandlerName: true})
+	r.GET("/users/:id", getUser)
+
+	serve(r, http.MethodGet, "/users/1")
+
+	if got, want := onlyTransaction(t, transport).Tags["gin.handler"], "github.com/Kichiyaki/sentrygin.getUser"; got != want {
+		t.Errorf("gin.handler tag = %q, want %q", got, want)
+	}
+}
+
+// withNewHub returns a copy of req whose context carries a new hub bound to the client of hub.
+func withNewHub(req *http.Request, hub *sentry.Hub) *http.Request {
+	return req.WithContext(sentry.SetHubOnContext(req.Context(), sentry.NewHub(hub.Client(), sentry.NewScope())))
+}
+
+func TestNewWithProvider(t *testing.T) {
+	hub, transport := newTestHub(t)
+	r := gin.New()
+	r.Use(NewWithProvider(func(c *gin.Context) Options {
+		if strings.HasPrefix(c.Request.URL.Path, "/admin/") {
+			return Options{DefaultTags: map[string]string{"area": "admin"}}
+		}
+		return Options{}
+	}))
+	r.GET("/admin/users", func(c *gin.Context) {})
+	r.GET("/users", func(c *gin.Context) {})
+
+	serveRequest(r, withNewHub(httptest.NewRequest(http.MethodGet, "/admin/users", nil), hub))
+	serveRequest(r, withNewHub(httptest.NewRequest(http.MethodGet, "/users", nil), hub))
+
+	transactions := transport.Transactions()
+	if len(transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(transactions))
+	}
+	if got := transactions[0].Tags["area"]; got != "admin" {
+		t.Errorf("area tag of %s = %q, want admin", transactions[0].Transaction, got)
+	}
+	if got, ok := transactions[1].Tags["area"]; ok {
+		t.Errorf("area tag of %s = %q, want none", transactions[1].Transaction, got)
+	}
+}
+
+func TestNewWithProviderSharedState(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	var samplerCalls int
+	hub, transport := newTestHub(t)
+	r := gin.New()
+	provider := NewWithProvider(func(c *gin.Context) Options {
+		return Options{
+			WarnIfUninitialized:      true,
+			DetectDoubleRegistration: true,
+			Logger:                   logger,
+			SamplerCacheSize:         8,
+			TransactionSampler: func(c *gin.Context) (float64, bool) {
+				samplerCalls++
+				return 1, true
+			},
+		}
+	})
+	r.Use(provider, provider)
+	r.GET("/users/:id", func(c *gin.Context) {})
+
+	for i := 0; i < 3; i++ {
+		serveRequest(r, withNewHub(httptest.NewRequest(http.MethodGet, "/users/1", nil), hub))
+	}
+
+	if got := len(transport.Transactions()); got != 3 {
+		t.Errorf("got %d transactions, want 3", got)
+	}
+	if samplerCalls != 1 {
+		t.Errorf("sampler called %d times, want the decision to be cached after the first request", samplerCalls)
+	}
+	if got := strings.Count(logs.String(), "registered twice"); got != 1 {
+		t.Errorf("double registration logged %d times, want once:\n%s", got, logs.String())
+	}
+	if got := strings.Count(logs.String(), "no client is bound"); got != 1 {
+		t.Errorf("missing client logged %d times, want once:\n%s", got, logs.String())
+	}
+}
+
+func TestNewWithProviderDistinctOptions(t *testing.T) {
+	var samplerCalls int
+	public := Options{}
+	admin := Options{
+		SamplerCacheSize: 8,
+		TransactionSampler: func(c *gin.Context) (float64, bool) {
+			samplerCalls++
+			return 1, true
+		},
+	}
+	hub, transport := newTestHub(t)
+	r := gin.New()
+	r.Use(NewWithProvider(func(c *gin.Context) Options {
+		if strings.HasPrefix(c.Request.URL.Path, "/admin/") {
+			return admin
+		}
+		return public
+	}))
+	r.GET("/admin/users", func(c *gin.Context) {})
+	r.GET("/users", func(c *gin.Context) {})
+
+	// the options of the first request don't cache sampling decisions
+	serveRequest(r, withNewHub(httptest.NewRequest(http.MethodGet, "/users", nil), hub))
+	for i := 0; i < 3; i++ {
+		serveRequest(r, withNewHub(httptest.NewRequest(http.MethodGet, "/admin/users", nil), hub))
+	}
+
+	if got := len(transport.Transactions()); got != 4 {
+		t.Errorf("got %d transactions, want 4", got)
+	}
+	if samplerCalls != 1 {
+		t.Errorf("sampler called %d times, want the decision to be cached after the first admin request", samplerCalls)
+	}
+}
+
+func TestCaptureContextErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		level sentry.Level
+	}{
+		{name: "canceled", ctx: canceled, level: sentry.LevelWarning},
+		{name: "deadline exceeded", ctx: expired, level: sentry.LevelError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{CaptureContextErrors: true})
+			r.GET("/users/:id", func(c *gin.Context) {})
+
+			serveRequest(r, httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(tt.ctx))
+
+			event := onlyEvent(t, transport)
+			if event.Level != tt.level {
+				t.Errorf("level = %q, want %q", event.Level, tt.level)
+			}
+			if want := "GET /users/:id: " + tt.ctx.Err().Error(); event.Message != want {
+				t.Errorf("message = %q, want %q", event.Message, want)
+			}
+			if event.Contexts["trace"]["trace_id"] != onlyTransaction(t, transport).Contexts["trace"]["trace_id"] {
+				t.Error("the event isn't correlated with the transaction")
+			}
+		})
+	}
+
+	t.Run("already reported", func(t *testing.T) {
+		r, transport := newRouter(t, Options{CaptureContextErrors: true, CaptureErrors: true})
+		r.GET("/", func(c *gin.Context) {
+			_ = c.Error(c.Request.Context().Err())
+		})
+
+		serveRequest(r, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(expired))
+
+		if event := onlyEvent(t, transport); len(event.Exception) == 0 {
+			t.Errorf("got message %q, want the error reported by the handler only", event.Message)
+		}
+	})
+}
+
+func TestUnmatchedTransactionName(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		want   string
+		source sentry.TransactionSource
+	}{
+		{name: "default", want: "GET /wp-login.php", source: sentry.SourceURL},
+		{
+			name:   "unmatched",
+			opts:   Options{UnmatchedTransactionName: "<unmatched>"},
+			want:   "GET <unmatched>",
+			source: sentry.SourceCustom,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/users/:id", func(c *gin.Context) {})
+
+			serve(r, http.MethodGet, "/wp-login.php")
+
+			transaction := onlyTransaction(t, transport)
+			if transaction.Transaction != tt.want {
+				t.Errorf("transaction = %q, want %q", transaction.Transaction, tt.want)
+			}
+			if transaction.TransactionInfo.Source != tt.source {
+				t.Errorf("source = %q, want %q", transaction.TransactionInfo.Source, tt.source)
+			}
+		})
+	}
+}
+
+func TestSpanFromContext(t *testing.T) {
+	t.Run("traced", func(t *testing.T) {
+		r, transport := newRouter(t, Options{})
+		var transactionSpanID sentry.SpanID
+		r.GET("/", func(c *gin.Context) {
+			span := SpanFromContext(c)
+			if span == nil {
+				t.Fatal("no span")
+			}
+			transactionSpanID = span.SpanID
+			span.StartChild("db.query").Finish()
+		})
+
+		serve(r, http.MethodGet, "/")
+
+		transaction := onlyTransaction(t, transport)
+		if got := transaction.Contexts["trace"]["span_id"]; got != transactionSpanID {
+			t.Errorf("span ID of the transaction = %v, want the span returned by SpanFromContext %v", got, transactionSpanID)
+		}
+		if len(transaction.Spans) != 1 || transaction.Spans[0].Op != "db.query" {
+			t.Errorf("spans = %+v, want the db.query child span", transaction.Spans)
+		}
+	})
+
+	t.Run("untraced", func(t *testing.T) {
+		r, _ := newRouter(t, Options{DisableTracing: true})
+		r.GET("/", func(c *gin.Context) {
+			if span := SpanFromContext(c); span != nil {
+				t.Errorf("span = %+v, want nil", span)
+			}
+		})
+
+		serve(r, http.MethodGet, "/")
+	})
+}
+
+func TestResponseHeaderContext(t *testing.T) {
+	r, transport := newRouter(t, Options{
+		CaptureErrors:         true,
+		ResponseHeaderContext: []string{"cache-control", "X-Cache", "X-Missing"},
+	})
+	r.GET("/", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Header("X-Cache", "MISS")
+		_ = c.Error(errors.New("cache failure"))
+	})
+
+	serve(r, http.MethodGet, "/")
+
+	want := sentry.Context{"Cache-Control": "no-store", "X-Cache": "MISS"}
+	if got := onlyEvent(t, transport).Contexts["response_headers"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("response_headers = %v, want %v", got, want)
+	}
+	if got := onlyTransaction(t, transport).Contexts["response_headers"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("response_headers of the transaction = %v, want %v", got, want)
+	}
+}
+
+// BenchmarkHubPerRequest measures the allocations of a 10k-request loop depending on how the hub
+// of the request is obtained: cloned from sentry.CurrentHub(), with a scope pushed onto it (ReuseHub)
+// or already present in the request context.
+func BenchmarkHubPerRequest(b *testing.B) {
+	const requests = 10000
+
+	tests := []struct {
+		name    string
+		opts    Options
+		context bool
+	}{
+		{name: "clone"},
+		{name: "reuse", opts: Options{ReuseHub: true}},
+		{name: "context", context: true},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			transport := bindCurrentHub(b)
+			r := gin.New()
+			r.Use(New(tt.opts))
+			r.GET("/users/:id", func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if tt.context {
+				req = req.WithContext(sentry.SetHubOnContext(req.Context(), sentry.CurrentHub().Clone()))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < requests; j++ {
+					r.ServeHTTP(httptest.NewRecorder(), req)
+				}
+				transport.Reset()
+			}
+		})
+	}
+}
+
+func TestDisableTracing(t *testing.T) {
+	r, transport := newRouter(t, Options{DisableTracing: true})
+	r.GET("/", func(c *gin.Context) {
+		if sentry.GetHubFromContext(c.Request.Context()) == nil {
+			t.Error("no hub on the request context")
+		}
+		panic("boom")
+	})
+
+	serve(r, http.MethodGet, "/")
+
+	if got := len(transport.Transactions()); got != 0 {
+		t.Errorf("got %d transactions, want none", got)
+	}
+	if event := onlyEvent(t, transport); event.Message != "boom" {
+		t.Errorf("message = %q, want the panic to be reported", event.Message)
+	}
+}
+
+func BenchmarkDisableTracing(b *testing.B) {
+	b.Run("traced", func(b *testing.B) {
+		benchmarkRequests(b, Options{})
+	})
+	b.Run("untraced", func(b *testing.B) {
+		benchmarkRequests(b, Options{DisableTracing: true})
+	})
+}
+
+func TestBeforeSpanFinish(t *testing.T) {
+	var statuses []sentry.SpanStatus
+	r, transport := newRouter(t, Options{
+		BeforeSpanFinish: func(c *gin.Context, span *sentry.Span) bool {
+			statuses = append(statuses, span.Status)
+			if c.Writer.Status() == http.StatusNotModified {
+				return false
+			}
+			if c.FullPath() == "/rename" {
+				span.Name = "GET renamed"
+			}
+			return true
+		},
+	})
+	r.GET("/cached", func(c *gin.Context) {
+		c.Status(http.StatusNotModified)
+	})
+	r.GET("/rename", func(c *gin.Context) {})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	serve(r, http.MethodGet, "/cached")
+	serve(r, http.MethodGet, "/rename")
+	serve(r, http.MethodGet, "/panic")
+
+	transactions := transport.Transactions()
+	if len(transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(transactions))
+	}
+	if got := transactions[0].Transaction; got != "GET renamed" {
+		t.Errorf("transaction = %q, want GET renamed", got)
+	}
+	if got := transactions[1].Transaction; got != "GET /panic" {
+		t.Errorf("transaction = %q, want GET /panic", got)
+	}
+	want := []sentry.SpanStatus{sentry.SpanStatusOK, sentry.SpanStatusOK, sentry.SpanStatusInternalError}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+}
+
+func TestContinueFromTraceparent(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	tests := []struct {
+		name        string
+		traceparent string
+		continued   bool
+	}{
+		{name: "valid", traceparent: "00-" + traceID + "-00f067aa0ba902b7-01", continued: true},
+		{name: "malformed", traceparent: "00-" + traceID + "-00f067aa0ba902b7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{ContinueFromTraceparent: true})
+			r.GET("/", func(c *gin.Context) {})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("traceparent", tt.traceparent)
+			serveRequest(r, req)
+
+			trace := onlyTransaction(t, transport).Contexts["trace"]
+			gotTraceID := trace["trace_id"].(sentry.TraceID).String()
+			if continued := gotTraceID == traceID; continued != tt.continued {
+				t.Errorf("trace ID = %s, continued = %t, want %t", gotTraceID, continued, tt.continued)
+			}
+			if _, hasParent := trace["parent_span_id"]; hasParent != tt.continued {
+				t.Errorf("parent span ID set = %t, want %t", hasParent, tt.continued)
+			}
+		})
+	}
+}
+
+func TestRecordPayloadSizes(t *testing.T) {
+	r, transport := newRouter(t, Options{RecordPayloadSizes: true})
+	r.POST("/upload", func(c *gin.Context) {
+		c.String(http.StatusOK, "uploaded")
+	})
+
+	serveRequest(r, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("0123456789")))
+
+	data := onlyTransaction(t, transport).Extra
+	if got := data["http.request_content_length"]; got != int64(10) {
+		t.Errorf("http.request_content_length = %v, want 10", got)
+	}
+	if got := data["http.response_content_length"]; got != len("uploaded") {
+		t.Errorf("http.response_content_length = %v, want %d", got, len("uploaded"))
+	}
+}
+
+type hubKey struct{}
+
+func TestContextHubKey(t *testing.T) {
+	r, _ := newRouter(t, Options{ContextHubKey: hubKey{}})
+	r.GET("/", func(c *gin.Context) {
+		hub, ok := c.Request.Context().Value(hubKey{}).(*sentry.Hub)
+		if !ok {
+			t.Fatal("no hub stored under the custom key")
+		}
+		if hub != GetHubFromContext(c) {
+			t.Error("the hub stored under the custom key isn't the hub of the request")
+		}
+		if sentry.GetHubFromContext(c.Request.Context()) != hub {
+			t.Error("the hub isn't stored under the key of the SDK anymore")
+		}
+	})
+
+	serve(r, http.MethodGet, "/")
+}
+
+func TestSkipRecover(t *testing.T) {
+	errSkipped := errors.New("skipped")
+	tests := []struct {
+		name      string
+		opts      Options
+		recovered interface{}
+		skipped   bool
+	}{
+		{name: "abort handler", recovered: http.ErrAbortHandler, skipped: true},
+		{name: "wrapped abort handler", recovered: fmt.Errorf("wrapped: %w", http.ErrAbortHandler), skipped: true},
+		{name: "error", recovered: errors.New("boom")},
+		{
+			name: "custom",
+			opts: Options{SkipRecover: func(recovered interface{}) bool {
+				return recovered == errSkipped
+			}},
+			recovered: errSkipped,
+			skipped:   true,
+		},
+		{
+			name: "custom replaces the default",
+			opts: Options{SkipRecover: func(recovered interface{}) bool {
+				return recovered == errSkipped
+			}},
+			recovered: http.ErrAbortHandler,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport := newTestHub(t)
+			var repanicked interface{}
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				defer func() {
+					repanicked = recover()
+				}()
+				c.Next()
+			}, NewWithClient(hub.Client(), tt.opts))
+			r.GET("/", func(c *gin.Context) {
+				panic(tt.recovered)
+			})
+
+			serve(r, http.MethodGet, "/")
+
+			if reported := len(transport.Events()) > 0; reported == tt.skipped {
+				t.Errorf("reported = %t, want %t", reported, !tt.skipped)
+			}
+			if wantRepanic := tt.skipped; (repanicked != nil) != wantRepanic {
+				t.Errorf("repanicked %v, want a repanic = %t", repanicked, wantRepanic)
+			}
+		})
+	}
+}
+
+func TestServerName(t *testing.T) {
+	transport := bindCurrentHub(t)
+	r := gin.New()
+	r.Use(New(Options{ServerName: "checkout-api", CaptureErrors: true}))
+	r.GET("/", func(c *gin.Context) {
+		_ = c.Error(errors.New("boom"))
+	})
+
+	serve(r, http.MethodGet, "/")
+
+	if got := onlyEvent(t, transport).ServerName; got != "checkout-api" {
+		t.Errorf("server name of the event = %q, want checkout-api", got)
+	}
+	if got := onlyTransaction(t, transport).ServerName; got != "checkout-api" {
+		t.Errorf("server name of the transaction = %q, want checkout-api", got)
+	}
+
+	transport.Reset()
+	sentry.CurrentHub().CaptureMessage("outside of a request")
+	if got := onlyEvent(t, transport).ServerName; got == "checkout-api" {
+		t.Error("the server name leaked into the current hub")
+	}
+}
+
+func TestTagMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		tagged bool
+	}{
+		{name: "default"},
+		{name: "tagged", opts: Options{TagMethodNotAllowed: true}, tagged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.HandleMethodNotAllowed = true
+			r.GET("/users", func(c *gin.Context) {})
+
+			rec := serve(r, http.MethodDelete, "/users")
+
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("status = %d, want 405", rec.Code)
+			}
+			transaction := onlyTransaction(t, transport)
+			if got := transaction.Tags["http.method_not_allowed"] == "true"; got != tt.tagged {
+				t.Errorf("tagged = %t, want %t", got, tt.tagged)
+			}
+			if got := transaction.Contexts["trace"]["status"]; got != sentry.SpanStatusInvalidArgument {
+				t.Errorf("status = %v, want %v", got, sentry.SpanStatusInvalidArgument)
+			}
+		})
+	}
+}
+
+func TestExtraContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		contextName string
+		want        sentry.Context
+	}{
+		{
+			name: "default name",
+			opts: Options{ExtraContext: func(c *gin.Context) map[string]interface{} {
+				return map[string]interface{}{"tenant": c.GetString("tenant")}
+			}},
+			contextName: "request_meta",
+			want:        sentry.Context{"tenant": "acme"},
+		},
+		{
+			name: "custom name",
+			opts: Options{
+				ExtraContext: func(c *gin.Context) map[string]interface{} {
+					return map[string]interface{}{"tenant": c.GetString("tenant")}
+				},
+				ExtraContextName: "tenant",
+			},
+			contextName: "tenant",
+			want:        sentry.Context{"tenant": "acme"},
+		},
+		{
+			name: "panicking callback",
+			opts: Options{ExtraContext: func(c *gin.Context) map[string]interface{} {
+				panic("broken callback")
+			}},
+			contextName: "request_meta",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport := newTestHub(t)
+			tt.opts.CaptureErrors = true
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				c.Set("tenant", "acme")
+			}, NewWithClient(hub.Client(), tt.opts))
+			r.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("boom"))
+			})
+
+			rec := serve(r, http.MethodGet, "/")
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want 200", rec.Code)
+			}
+			got, ok := onlyEvent(t, transport).Contexts[tt.contextName]
+			if tt.want == nil && ok {
+				t.Errorf("%s = %v, want none", tt.contextName, got)
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.contextName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaptureQueryParams(t *testing.T) {
+	r, transport := newRouter(t, Options{
+		CaptureQueryParams: true,
+		ScrubQueryParams:   []string{"Token", "signature"},
+	})
+	var rawQuery string
+	r.GET("/search", func(c *gin.Context) {
+		rawQuery = c.Request.URL.RawQuery
+	})
+
+	serve(r, http.MethodGet, "/search?q=shoes&tag=a&tag=b&token=secret&SIGNATURE=abc")
+
+	want := map[string][]string{
+		"q":         {"shoes"},
+		"tag":       {"a", "b"},
+		"token":     {filteredValue},
+		"SIGNATURE": {filteredValue},
+	}
+	got, ok := onlyTransaction(t, transport).Extra["http.query"].(map[string][]string)
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("http.query = %v, want %v", got, want)
+	}
+	if want := "q=shoes&tag=a&tag=b&token=secret&SIGNATURE=abc"; rawQuery != want {
+		t.Errorf("query of the request = %q, want %q", rawQuery, want)
+	}
+}
+
+func BenchmarkSamplerCache(b *testing.B) {
+	for _, size := range []int{0, 16} {
+		b.Run("size="+strconv.Itoa(size), func(b *testing.B) {
+			var calls int64
+			pattern := regexp.MustCompile(`^/(users|orders)/:id(/items/:itemID)?$`)
+			r, transport := newRouter(b, Options{
+				SamplerCacheSize: size,
+				TransactionSampler: func(c *gin.Context) (float64, bool) {
+					atomic.AddInt64(&calls, 1)
+					if pattern.MatchString(c.FullPath()) {
+						return 1, true
+					}
+					return 0.1, true
+				},
+			})
+			r.GET("/users/:id", func(c *gin.Context) {})
+			r.GET("/orders/:id/items/:itemID", func(c *gin.Context) {})
+			requests := []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/users/1", nil),
+				httptest.NewRequest(http.MethodGet, "/orders/1/items/2", nil),
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					r.ServeHTTP(httptest.NewRecorder(), requests[i%len(requests)])
+				}
+			})
+			b.StopTimer()
+			transport.Reset()
+			b.ReportMetric(float64(atomic.LoadInt64(&calls))/float64(b.N), "sampler-calls/op")
+		})
+	}
+}
+
+func TestTagGinMode(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r, transport := newRouter(t, Options{TagGinMode: true})
+	// The mode is read when the middleware is created, changing it afterwards has no effect.
+	gin.SetMode(gin.TestMode)
+	r.GET("/", func(c *gin.Context) {})
+
+	serve(r, http.MethodGet, "/")
+
+	if got := onlyTransaction(t, transport).Tags["gin.mode"]; got != gin.ReleaseMode {
+		t.Errorf("gin.mode tag = %q, want %q", got, gin.ReleaseMode)
+	}
+}
+
+func TestEventProcessors(t *testing.T) {
+	var order []string
+	r, transport := newRouter(t, Options{
+		CaptureErrors: true,
+		EventProcessors: []sentry.EventProcessor{
+			func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+				order = append(order, "first")
+				if event.Request != nil && strings.HasSuffix(event.Request.URL, "/internal") {
+					return nil
+				}
+				return event
+			},
+			func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+				order = append(order, "second")
+				return event
+			},
+		},
+	})
+	handler := func(c *gin.Context) {
+		_ = c.Error(errors.New("boom"))
+	}
+	r.GET("/internal", handler)
+	r.GET("/public", handler)
+
+	serve(r, http.MethodGet, "/internal")
+	serve(r, http.MethodGet, "/public")
+
+	if event := onlyEvent(t, transport); !strings.HasSuffix(event.Request.URL, "/public") {
+		t.Errorf("reported %s, want the events of /internal to be dropped", event.Request.URL)
+	}
+	if got := onlyTransaction(t, transport).Transaction; got != "GET /public" {
+		t.Errorf("transaction = %q, want the transaction of /internal to be dropped", got)
+	}
+	// Two events per request (the error and the transaction), the second processor isn't called for dropped ones.
+	want := []string{"first", "first", "first", "second", "first", "second"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("processors called in order %v, want %v", order, want)
+	}
+}
+
+func TestFlushTimeout(t *testing.T) {
+	h := newHandler(Options{Timeout: 2 * time.Second, RespectContextDeadline: true}, &handlerState{})
+	near, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		atLeast time.Duration
+		atMost  time.Duration
+	}{
+		{name: "no deadline", ctx: context.Background(), atLeast: 2 * time.Second, atMost: 2 * time.Second},
+		{name: "far deadline", ctx: WithFlushTimeout(context.Background(), 5*time.Second), atLeast: 5 * time.Second, atMost: 5 * time.Second},
+		{name: "near deadline", ctx: near, atLeast: time.Nanosecond, atMost: 500 * time.Millisecond},
+		{name: "near deadline with an override", ctx: WithFlushTimeout(near, 5*time.Second), atLeast: time.Nanosecond, atMost: 500 * time.Millisecond},
+		{name: "expired deadline", ctx: expired, atLeast: -time.Hour, atMost: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.flushTimeout(tt.ctx); got < tt.atLeast || got > tt.atMost {
+				t.Errorf("flushTimeout() = %v, want between %v and %v", got, tt.atLeast, tt.atMost)
+			}
+		})
+	}
+}
+
+func TestRespectContextDeadlineExpired(t *testing.T) {
+	fake := &fakeHub{delivered: true}
+	r, transport := newFakeHubRouter(t, Options{WaitForDelivery: true, RespectContextDeadline: true}, fake)
+	r.GET("/", func(c *gin.Context) {
+		panic("boom")
+	})
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	serveRequest(r, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	onlyEvent(t, transport)
+	if flushes := fake.flushTimeouts(); len(flushes) != 0 {
+		t.Errorf("flushes = %v, want none once the deadline has passed", flushes)
+	}
+}
+
+func TestSetTraceIDHeader(t *testing.T) {
+	r, transport := newRouter(t, Options{SetTraceIDHeader: "X-Trace-Id"})
+	var stored string
+	r.GET("/", func(c *gin.Context) {
+		stored = c.GetString(TraceIDKey)
+	})
+
+	rec := serve(r, http.MethodGet, "/")
+
+	traceID := rec.Header().Get("X-Trace-Id")
+	if !isHex(traceID, 32) {
+		t.Errorf("X-Trace-Id = %q, want 32 hex digits", traceID)
+	}
+	if stored != traceID {
+		t.Errorf("trace ID stored under TraceIDKey = %q, want %q", stored, traceID)
+	}
+	if got := onlyTransaction(t, transport).Contexts["trace"]["trace_id"].(sentry.TraceID).String(); got != traceID {
+		t.Errorf("trace ID of the transaction = %s, want %s", got, traceID)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{name: "default", want: "GET /files/42/raw"},
+		{name: "built-in", opts: Options{NormalizeIDs: true}, want: "GET /files/:id/raw"},
+		{
+			name: "custom",
+			opts: Options{
+				NormalizeIDs: true,
+				NormalizePath: func(path string) string {
+					return strings.ToUpper(path)
+				},
+			},
+			want: "GET /FILES/42/RAW",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.NoRoute(func(c *gin.Context) {})
+
+			serve(r, http.MethodGet, "/files/42/raw")
+
+			if got := onlyTransaction(t, transport).Transaction; got != tt.want {
+				t.Errorf("transaction = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnEventCaptured(t *testing.T) {
+	var captured []sentry.EventID
+	r, transport := newRouter(t, Options{
+		CaptureErrors: true,
+		OnEventCaptured: func(eventID *sentry.EventID) {
+			captured = append(captured, *eventID)
+		},
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	r.GET("/error", func(c *gin.Context) {
+		_ = c.Error(errors.New("boom"))
+	})
+	r.GET("/ok", func(c *gin.Context) {})
+
+	serve(r, http.MethodGet, "/panic")
+	serve(r, http.MethodGet, "/error")
+	serve(r, http.MethodGet, "/ok")
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if want := []sentry.EventID{events[0].EventID, events[1].EventID}; !reflect.DeepEqual(captured, want) {
+		t.Errorf("captured = %v, want %v", captured, want)
+	}
+}
+
+func TestTagRouteAndStatusCode(t *testing.T) {
+	r, transport := newRouter(t, Options{TagRoute: true, TagStatusCode: true})
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	serve(r, http.MethodGet, "/users/12345")
+
+	tags := onlyTransaction(t, transport).Tags
+	if got := tags["route"]; got != "/users/:id" {
+		t.Errorf("route tag = %q, want /users/:id", got)
+	}
+	if got := tags["status_code"]; got != "404" {
+		t.Errorf("status_code tag = %q, want 404", got)
+	}
+}
+
+func TestDeduplicatePanics(t *testing.T) {
+	tests := []struct {
+		name        string
+		deduplicate bool
+		want        int
+	}{
+		{name: "default", want: 2},
+		{name: "deduplicated", deduplicate: true, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport := newTestHub(t)
+			r := gin.New()
+			// The inner middleware propagates the panic once reported, so the outer one recovers it as well.
+			r.Use(
+				NewWithClient(hub.Client(), Options{DeduplicatePanics: tt.deduplicate}),
+				NewWithClient(hub.Client(), Options{DeduplicatePanics: tt.deduplicate, Repanic: true}),
+			)
+			r.GET("/", func(c *gin.Context) {
+				panic("boom")
+			})
+
+			serve(r, http.MethodGet, "/")
+
+			if got := len(transport.Events()); got != tt.want {
+				t.Errorf("got %d events, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		target string
+		want   sentry.TransactionSource
+	}{
+		{
+			name: "callback",
+			opts: Options{TransactionName: func(c *gin.Context) string {
+				return "custom"
+			}},
+			target: "/users/1",
+			want:   sentry.SourceCustom,
+		},
+		{name: "route", target: "/users/1", want: sentry.SourceRoute},
+		{name: "raw path", target: "/unknown", want: sentry.SourceURL},
+		{
+			name:   "override",
+			opts:   Options{TransactionSource: sentry.SourceView},
+			target: "/users/1",
+			want:   sentry.SourceView,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/users/:id", func(c *gin.Context) {})
+
+			serve(r, http.MethodGet, tt.target)
+
+			if got := onlyTransaction(t, transport).TransactionInfo.Source; got != tt.want {
+				t.Errorf("source = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionNameHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		header string
+		want   string
+	}{
+		{
+			name:   "present",
+			opts:   Options{TransactionNameHeader: "X-Original-Route"},
+			header: " /orders/:id ",
+			want:   "GET /orders/:id",
+		},
+		{
+			name: "absent",
+			opts: Options{TransactionNameHeader: "X-Original-Route"},
+			want: "GET /api/*path",
+		},
+		{
+			name:   "too long",
+			opts:   Options{TransactionNameHeader: "X-Original-Route"},
+			header: "/" + strings.Repeat("a", maxNameHeaderLength),
+			want:   "GET /api/*path",
+		},
+		{
+			name: "allowed",
+			opts: Options{
+				TransactionNameHeader:   "X-Original-Route",
+				AllowedTransactionNames: []string{"/orders/:id"},
+			},
+			header: "/orders/:id",
+			want:   "GET /orders/:id",
+		},
+		{
+			name: "not allowed",
+			opts: Options{
+				TransactionNameHeader:   "X-Original-Route",
+				AllowedTransactionNames: []string{"/orders/:id"},
+			},
+			header: "/orders/12345",
+			want:   "GET /api/*path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/api/*path", func(c *gin.Context) {})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/1", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Original-Route", tt.header)
+			}
+			serveRequest(r, req)
+
+			if got := onlyTransaction(t, transport).Transaction; got != tt.want {
+				t.Errorf("transaction = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagContentTypes(t *testing.T) {
+	r, transport := newRouter(t, Options{TagContentTypes: true})
+	r.POST("/users", func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Jane"}`))
+	req.Header.Set("Content-Type", "Application/JSON; charset=utf-8")
+	serveRequest(r, req)
+
+	tags := onlyTransaction(t, transport).Tags
+	if got := tags["request_content_type"]; got != "application/json" {
+		t.Errorf("request_content_type tag = %q, want application/json", got)
+	}
+	if got := tags["response_content_type"]; got != "application/json" {
+		t.Errorf("response_content_type tag = %q, want application/json", got)
+	}
+}
+
+func TestMaxBreadcrumbs(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		want []string
+	}{
+		{name: "unbounded", want: []string{"http", "first", "second", "third"}},
+		{name: "bounded", max: 2, want: []string{"http", "first"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{
+				AddRequestBreadcrumb:  true,
+				AddErrorBreadcrumbs:   true,
+				MaxBreadcrumbs:        tt.max,
+				CaptureResponseErrors: func(status int) bool { return status >= http.StatusInternalServerError },
+			})
+			r.GET("/", func(c *gin.Context) {
+				_ = c.Error(errors.New("first"))
+				_ = c.Error(errors.New("second"))
+				_ = c.Error(errors.New("third"))
+				c.Status(http.StatusInternalServerError)
+			})
+
+			serve(r, http.MethodGet, "/")
+
+			var got []string
+			for _, breadcrumb := range onlyEvent(t, transport).Breadcrumbs {
+				if breadcrumb.Category == "gin.error" {
+					got = append(got, breadcrumb.Message)
+				} else {
+					got = append(got, breadcrumb.Category)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("breadcrumbs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpanPerHandler(t *testing.T) {
+	r, transport := newRouter(t, Options{SpanPerHandler: true})
+	r.GET("/users/:id", getUser, func(c *gin.Context) {
+		SpanFromContext(c).StartChild("db.query").Finish()
+		c.Status(http.StatusNotFound)
+	})
+
+	serve(r, http.MethodGet, "/users/1")
+
+	transaction := onlyTransaction(t, transport)
+	spans := make(map[string]*sentry.Span)
+	for _, span := range transaction.Spans {
+		spans[span.Op] = span
+	}
+	handler, query := spans["gin.handler"], spans["db.query"]
+	if handler == nil || query == nil {
+		t.Fatalf("spans = %+v, want a gin.handler and a db.query span", transaction.Spans)
+	}
+	if handler.ParentSpanID != transaction.Contexts["trace"]["span_id"] {
+		t.Errorf("parent of the gin.handler span = %s, want the transaction", handler.ParentSpanID)
+	}
+	if query.ParentSpanID != handler.SpanID {
+		t.Errorf("parent of the db.query span = %s, want the gin.handler span", query.ParentSpanID)
+	}
+	if handler.Status != sentry.SpanStatusNotFound {
+		t.Errorf("status of the gin.handler span = %v, want %v", handler.Status, sentry.SpanStatusNotFound)
+	}
+	if !strings.HasSuffix(handler.Description, ".func1") {
+		t.Errorf("description = %q, want the name of the last handler", handler.Description)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	hub, transport, err := testtransport.NewHub(sentry.ClientOptions{Release: "sdk@1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(NewWithClient(hub.Client(), Options{Release: "app@abc123"}))
+	r.GET("/", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	serve(r, http.MethodGet, "/")
+
+	if got := onlyEvent(t, transport).Release; got != "app@abc123" {
+		t.Errorf("release of the event = %q, want app@abc123", got)
+	}
+	if got := onlyTransaction(t, transport).Release; got != "app@abc123" {
+		t.Errorf("release of the transaction = %q, want app@abc123", got)
+	}
+}
+
+var errInvalidInput = errors.New("invalid input")
+
+func TestErrorLevel(t *testing.T) {
+	r, transport := newRouter(t, Options{
+		CaptureErrors:    true,
+		ReportErrorTypes: gin.ErrorTypePrivate | gin.ErrorTypeBind,
+		ErrorLevel: func(err error) sentry.Level {
+			if errors.Is(err, errInvalidInput) {
+				return sentry.LevelWarning
+			}
+			return sentry.LevelError
+		},
+	})
+	r.GET("/", func(c *gin.Context) {
+		_ = c.Error(fmt.Errorf("binding: %w", errInvalidInput)).SetType(gin.ErrorTypeBind)
+		_ = c.Error(errors.New("database unavailable"))
+		_ = c.Error(errors.New("public")).SetType(gin.ErrorTypePublic)
+	})
+
+	serve(r, http.MethodGet, "/")
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].Level != sentry.LevelWarning {
+		t.Errorf("level of the validation error = %q, want warning", events[0].Level)
+	}
+	if events[1].Level != sentry.LevelError {
+		t.Errorf("level of the internal error = %q, want error", events[1].Level)
+	}
+}
+
+func TestNewWithClient(t *testing.T) {
+	global := bindCurrentHub(t)
+	upstream, upstreamTransport := newTestHub(t)
+	tenant, tenantTransport := newTestHub(t)
+	r := gin.New()
+	r.Use(NewWithClient(tenant.Client(), Options{}))
+	r.GET("/", func(c *gin.Context) {
+		hub := GetHubFromContext(c)
+		if hub.Client() != tenant.Client() {
+			t.Error("the hub of the request isn't bound to the injected client")
+		}
+		if sentry.GetHubFromContext(c.Request.Context()) != hub {
+			t.Error("the hub of the request context isn't the hub of the request")
+		}
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	serveRequest(r, req.WithContext(sentry.SetHubOnContext(req.Context(), upstream)))
+
+	onlyEvent(t, tenantTransport)
+	onlyTransaction(t, tenantTransport)
+	if got := len(global.Events()) + len(global.Transactions()) + len(upstreamTransport.Events()) + len(upstreamTransport.Transactions()); got != 0 {
+		t.Errorf("%d events reported to the other clients, want none", got)
+	}
+}
+
+func TestTransactionInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want sentry.TransactionSource
+	}{
+		{name: "route", want: sentry.SourceRoute},
+		{name: "overridden", opts: Options{TransactionSource: sentry.SourceComponent}, want: sentry.SourceComponent},
+		{
+			name: "renamed",
+			opts: Options{FinalTransactionName: func(c *gin.Context) string {
+				return "renamed"
+			}},
+			want: sentry.SourceCustom,
+		},
+		{
+			name: "renamed and overridden",
+			opts: Options{
+				FinalTransactionName: func(c *gin.Context) string {
+					return "renamed"
+				},
+				TransactionSource: sentry.SourceComponent,
+			},
+			want: sentry.SourceComponent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/users/:id", func(c *gin.Context) {})
+
+			serve(r, http.MethodGet, "/users/1")
+
+			info := onlyTransaction(t, transport).TransactionInfo
+			if info == nil || info.Source != tt.want {
+				t.Errorf("transaction info = %+v, want source %q", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagUserAgentAndReferer(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		userAgent string
+		referer   string
+		want      map[string]string
+	}{
+		{
+			name:      "both",
+			opts:      Options{TagUserAgent: true, TagReferer: true},
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/117.0",
+			referer:   "https://www.google.com/search?q=gin",
+			want:      map[string]string{"user_agent": "Firefox", "referer": "www.google.com"},
+		},
+		{
+			name: "absent headers",
+			opts: Options{TagUserAgent: true, TagReferer: true},
+			want: map[string]string{},
+		},
+		{
+			name: "custom normalization",
+			opts: Options{TagUserAgent: true, NormalizeUserAgent: func(userAgent string) string {
+				return strings.ToUpper(userAgent)
+			}},
+			userAgent: "curl/8.1.2",
+			referer:   "https://www.google.com/",
+			want:      map[string]string{"user_agent": "CURL/8.1.2"},
+		},
+		{
+			name:      "disabled",
+			userAgent: "curl/8.1.2",
+			referer:   "https://www.google.com/",
+			want:      map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/", func(c *gin.Context) {})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.userAgent != "" {
+				req.Header.Set("User-Agent", tt.userAgent)
+			}
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+			serveRequest(r, req)
+
+			tags := onlyTransaction(t, transport).Tags
+			got := map[string]string{}
+			for _, key := range []string{"user_agent", "referer"} {
+				if value, ok := tags[key]; ok {
+					got[key] = value
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinalTransactionName(t *testing.T) {
+	r, transport := newRouter(t, Options{
+		FinalTransactionName: func(c *gin.Context) string {
+			if operation := c.GetString("graphql.operation"); operation != "" {
+				return "graphql " + operation
+			}
+			return ""
+		},
+	})
+	r.POST("/graphql", func(c *gin.Context) {
+		if c.Query("op") != "" {
+			c.Set("graphql.operation", c.Query("op"))
+		}
+	})
+
+	serve(r, http.MethodPost, "/graphql?op=GetUser")
+	serve(r, http.MethodPost, "/graphql")
+
+	transactions := transport.Transactions()
+	if len(transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(transactions))
+	}
+	if got := transactions[0].Transaction; got != "graphql GetUser" {
+		t.Errorf("transaction = %q, want graphql GetUser", got)
+	}
+	if got := transactions[1].Transaction; got != "POST /graphql" {
+		t.Errorf("transaction = %q, want the name to be kept", got)
+	}
+}
+
+func TestDisableRepanic(t *testing.T) {
+	hub, transport := newTestHub(t)
+	repanicked := map[string]bool{}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				repanicked[c.Request.URL.Path] = true
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		c.Next()
+	}, NewWithClient(hub.Client(), Options{Repanic: true}))
+	r.GET("/experimental", func(c *gin.Context) {
+		DisableRepanic(c)
+		defer func() {
+			c.String(http.StatusTeapot, "custom body")
+		}()
+		panic("boom")
+	})
+	r.GET("/regular", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	rec := serve(r, http.MethodGet, "/experimental")
+	serve(r, http.MethodGet, "/regular")
+
+	if repanicked["/experimental"] {
+		t.Error("the panic of /experimental has been propagated")
+	}
+	if rec.Code != http.StatusTeapot || rec.Body.String() != "custom body" {
+		t.Errorf("response = %d %q, want the custom response", rec.Code, rec.Body.String())
+	}
+	if !repanicked["/regular"] {
+		t.Error("the panic of /regular hasn't been propagated")
+	}
+	if got := len(transport.Events()); got != 2 {
+		t.Errorf("got %d events, want both panics to be reported", got)
+	}
+}
+
+func TestRecordTTFB(t *testing.T) {
+	r, transport := newRouter(t, Options{RecordTTFB: true, RecordDurationMeasurement: true})
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(20 * time.Millisecond)
+		c.String(http.StatusOK, "first byte")
+		time.Sleep(20 * time.Millisecond)
+	})
+	r.GET("/empty", func(c *gin.Context) {})
+
+	serve(r, http.MethodGet, "/slow")
+	serve(r, http.MethodGet, "/empty")
+
+	transactions := transport.Transactions()
+	if len(transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(transactions))
+	}
+	ttfb, ok := transactions[0].Extra["ttfb_ms"].(float64)
+	if !ok || ttfb < 20 {
+		t.Fatalf("ttfb_ms = %v, want at least 20", transactions[0].Extra["ttfb_ms"])
+	}
+	if duration := transactions[0].Extra["duration_ms"].(float64); ttfb > duration-20 {
+		t.Errorf("ttfb_ms = %v, want it to exclude the time spent after the first write (duration_ms = %v)", ttfb, duration)
+	}
+	if got, ok := transactions[1].Extra["ttfb_ms"]; ok {
+		t.Errorf("ttfb_ms = %v, want none when nothing has been written", got)
+	}
+}
+
+func TestSkipMethods(t *testing.T) {
+	r, transport := newRouter(t, Options{SkipMethods: []string{"options"}})
+	r.OPTIONS("/users", func(c *gin.Context) {
+		panic("boom")
+	})
+	r.GET("/users", func(c *gin.Context) {})
+
+	serve(r, http.MethodOptions, "/users")
+	serve(r, http.MethodGet, "/users")
+
+	if got := onlyTransaction(t, transport).Transaction; got != "GET /users" {
+		t.Errorf("transaction = %q, want GET /users only", got)
+	}
+	if event := onlyEvent(t, transport); event.Message != "boom" {
+		t.Errorf("message = %q, want the panic of the skipped request to be reported", event.Message)
+	}
+}
+
+func TestSkipMethodAllocs(t *testing.T) {
+	h := newHandler(Options{SkipMethods: []string{http.MethodOptions, http.MethodHead}}, &handlerState{})
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		h.skipMethod(http.MethodGet)
+		h.skipMethod("head")
+	}); allocs != 0 {
+		t.Errorf("skipMethod allocates %v times, want none", allocs)
+	}
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		ip   string
+		want string
+	}{
+		{
+			name: "IPv4",
+			opts: Options{SetClientIP: true, AnonymizeIP: TruncateIP},
+			ip:   "203.0.113.42",
+			want: "203.0.113.0",
+		},
+		{
+			name: "IPv6",
+			opts: Options{SetClientIP: true, AnonymizeIP: TruncateIP},
+			ip:   "2001:db8::7334",
+			want: "2001:db8::",
+		},
+		{
+			name: "extracted address",
+			opts: Options{
+				SetClientIP: true,
+				AnonymizeIP: TruncateIP,
+				UserExtractor: func(c *gin.Context) sentry.User {
+					return sentry.User{ID: "42", IPAddress: "198.51.100.7"}
+				},
+			},
+			ip:   "203.0.113.42",
+			want: "198.51.100.0",
+		},
+		{
+			name: "client IP disabled",
+			opts: Options{AnonymizeIP: TruncateIP},
+			ip:   "203.0.113.42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/", func(c *gin.Context) {})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = net.JoinHostPort(tt.ip, "1234")
+			serveRequest(r, req)
+
+			if got := onlyTransaction(t, transport).User.IPAddress; got != tt.want {
+				t.Errorf("IP address = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordHandlerCount(t *testing.T) {
+	r, transport := newRouter(t, Options{RecordHandlerCount: true})
+	noop := func(c *gin.Context) {}
+	r.Use(noop)
+	r.GET("/users", noop, noop, getUser)
+
+	serve(r, http.MethodGet, "/users")
+
+	// The middleware, the global noop middleware, the two route middlewares and the handler.
+	if got := onlyTransaction(t, transport).Extra["gin.handler_count"]; got != 5 {
+		t.Errorf("gin.handler_count = %v, want 5", got)
+	}
+}
+
+// decodeReplayContext decodes a base64 encoded "<trace ID>:<span ID>" trace context.
+func decodeReplayContext(value string) (SpanContext, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	traceID, spanID, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if n, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil || n != len(sc.TraceID) {
+		return SpanContext{}, false
+	}
+	if n, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil || n != len(sc.SpanID) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = sentry.SampledTrue
+
+	return sc, true
+}
+
+func TestTraceContextHeader(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name        string
+		header      string
+		sentryTrace string
+		traceID     string
+		parent      string
+	}{
+		{
+			name:    "valid",
+			header:  base64.StdEncoding.EncodeToString([]byte(traceID + ":" + spanID)),
+			traceID: traceID,
+			parent:  spanID,
+		},
+		{name: "garbage", header: "%%% not base64 %%%"},
+		{name: "undecodable", header: base64.StdEncoding.EncodeToString([]byte("garbage"))},
+		{
+			name:        "sentry-trace takes precedence",
+			header:      base64.StdEncoding.EncodeToString([]byte(traceID + ":" + spanID)),
+			sentryTrace: "0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-1",
+			traceID:     "0af7651916cd43dd8448eb211c80319c",
+			parent:      "b7ad6b7169203331",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{
+				TraceContextHeader: "X-Replay-Trace",
+				DecodeTraceContext: decodeReplayContext,
+			})
+			r.POST("/jobs", func(c *gin.Context) {})
+
+			req := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+			req.Header.Set("X-Replay-Trace", tt.header)
+			if tt.sentryTrace != "" {
+				req.Header.Set(sentry.SentryTraceHeader, tt.sentryTrace)
+			}
+			serveRequest(r, req)
+
+			trace := onlyTransaction(t, transport).Contexts["trace"]
+			gotTraceID := trace["trace_id"].(sentry.TraceID).String()
+			if tt.traceID != "" && gotTraceID != tt.traceID {
+				t.Errorf("trace ID = %s, want %s", gotTraceID, tt.traceID)
+			}
+			if tt.traceID == "" && gotTraceID == traceID {
+				t.Error("the trace of an invalid header has been continued")
+			}
+			parent, ok := trace["parent_span_id"].(sentry.SpanID)
+			if tt.parent == "" && ok {
+				t.Errorf("parent span ID = %s, want none", parent)
+			}
+			if tt.parent != "" && parent.String() != tt.parent {
+				t.Errorf("parent span ID = %s, want %s", parent, tt.parent)
+			}
+		})
+	}
+}
+
+func TestPromoteContextKeys(t *testing.T) {
+	keys := []string{"tenant_id", "premium", "shard", "claims", "missing"}
+	for i := 0; i < maxPromotedKeys; i++ {
+		keys = append(keys, "key_"+strconv.Itoa(i))
+	}
+
+	r, transport := newRouter(t, Options{PromoteContextKeys: keys})
+	r.GET("/users", func(c *gin.Context) {
+		c.Set("tenant_id", "acme")
+		c.Set("premium", true)
+		c.Set("shard", 3)
+		c.Set("claims", map[string]string{"sub": "1"})
+		for i := 0; i < maxPromotedKeys; i++ {
+			c.Set("key_"+strconv.Itoa(i), "value")
+		}
+	})
+
+	serve(r, http.MethodGet, "/users")
+
+	tags := onlyTransaction(t, transport).Tags
+	for key, want := range map[string]string{"tenant_id": "acme", "premium": "true", "shard": "3"} {
+		if got := tags[key]; got != want {
+			t.Errorf("tag %s = %q, want %q", key, got, want)
+		}
+	}
+	if _, ok := tags["claims"]; ok {
+		t.Error("a map has been promoted to a tag")
+	}
+	if _, ok := tags["missing"]; ok {
+		t.Error("a missing key has been promoted to a tag")
+	}
+	promoted := 0
+	for i := 0; i < maxPromotedKeys; i++ {
+		if _, ok := tags["key_"+strconv.Itoa(i)]; ok {
+			promoted++
+		}
+	}
+	// the first 5 keys count towards the bound as well
+	if want := maxPromotedKeys - 5; promoted != want {
+		t.Errorf("%d of the keys past the 5th have been promoted, want %d", promoted, want)
+	}
+}
+
+// eventAttachments returns the filenames and payloads of the attachments of event,
+// which are not exported by sentry.Event.
+func eventAttachments(event *sentry.Event) map[string][]byte {
+	attachments := reflect.ValueOf(event).Elem().FieldByName("attachments")
+	files := make(map[string][]byte, attachments.Len())
+	for i := 0; i < attachments.Len(); i++ {
+		attachment := attachments.Index(i).Elem()
+		files[attachment.FieldByName("Filename").String()] = attachment.FieldByName("Payload").Bytes()
+	}
+
+	return files
+}
+
+func TestAttachGoroutineDump(t *testing.T) {
+	r, transport := newRouter(t, Options{AttachGoroutineDump: true})
+	r.GET("/users/:id", func(c *gin.Context) {
+		panic("deadlock")
+	})
+	r.GET("/users", func(c *gin.Context) {})
+
+	serve(r, http.MethodGet, "/users/1")
+
+	event := onlyEvent(t, transport)
+	dump, ok := eventAttachments(event)["goroutines.txt"]
+	if !ok {
+		t.Fatal("the event has no goroutines.txt attachment")
+	}
+	if !bytes.Contains(dump, []byte("goroutine ")) {
+		t.Errorf("goroutines.txt doesn't contain goroutine stacks: %.200s", dump)
+	}
+	if len(dump) > maxGoroutineDumpSize {
+		t.Errorf("goroutines.txt is %d bytes long, want at most %d", len(dump), maxGoroutineDumpSize)
+	}
+	for _, transaction := range transport.Transactions() {
+		if _, ok := eventAttachments(transaction)["goroutines.txt"]; ok {
+			t.Error("the dump has been attached to the transaction")
+		}
+	}
+
+	transport.Reset()
+	serve(r, http.MethodGet, "/users")
+	for _, transaction := range transport.Transactions() {
+		if len(eventAttachments(transaction)) != 0 {
+			t.Error("the dump has been attached to the transaction of a request without a panic")
+		}
+	}
+}
+
+func TestEnvironmentHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "allowed", header: "staging", want: "staging"},
+		{name: "not allowed", header: "attacker-chosen", want: "production"},
+		{name: "missing", want: "production"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport, err := testtransport.NewHub(sentry.ClientOptions{Environment: "production"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := gin.New()
+			r.Use(NewWithClient(hub.Client(), Options{
+				EnvironmentHeader:   "X-Environment",
+				AllowedEnvironments: []string{"staging", "canary"},
+			}))
+			r.GET("/users/:id", func(c *gin.Context) {
+				panic("user not found")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Environment", tt.header)
+			}
+			serveRequest(r, req)
+
+			if got := onlyEvent(t, transport).Environment; got != tt.want {
+				t.Errorf("event environment = %q, want %q", got, tt.want)
+			}
+			if got := onlyTransaction(t, transport).Environment; got != tt.want {
+				t.Errorf("transaction environment = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForceWaitForDelivery(t *testing.T) {
+	fake := &fakeHub{delivered: true}
+	r, transport := newFakeHubRouter(t, Options{Timeout: time.Second}, fake)
+	r.GET("/critical", func(c *gin.Context) {
+		ForceWaitForDelivery(c)
+		panic("payment failed")
+	})
+	r.GET("/users", func(c *gin.Context) {
+		panic("user not found")
+	})
+
+	serve(r, http.MethodGet, "/users")
+	if flushes := fake.flushTimeouts(); len(flushes) != 0 {
+		t.Fatalf("an unflagged request has flushed %d times, want 0", len(flushes))
+	}
+
+	serve(r, http.MethodGet, "/critical")
+	flushes := fake.flushTimeouts()
+	if len(flushes) != 1 {
+		t.Fatalf("a flagged request has flushed %d times, want 1", len(flushes))
+	}
+	if flushes[0] != time.Second {
+		t.Errorf("flush timeout = %s, want %s", flushes[0], time.Second)
+	}
+	if events := transport.Events(); len(events) != 2 {
+		t.Errorf("%d events have been reported, want 2", len(events))
+	}
+}
+
+func TestSkipStaticAssets(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		target       string
+		transactions int
+	}{
+		{name: "asset", opts: Options{SkipStaticAssets: true}, target: "/assets/logo.png"},
+		{name: "uppercase extension", opts: Options{SkipStaticAssets: true}, target: "/assets/LOGO.PNG"},
+		{name: "route", opts: Options{SkipStaticAssets: true}, target: "/assets/users", transactions: 1},
+		{name: "disabled", target: "/assets/logo.png", transactions: 1},
+		{
+			name:         "custom extensions",
+			opts:         Options{SkipStaticAssets: true, StaticExtensions: []string{"wasm"}},
+			target:       "/assets/logo.png",
+			transactions: 1,
+		},
+		{
+			name:   "custom extension",
+			opts:   Options{SkipStaticAssets: true, StaticExtensions: []string{"wasm"}},
+			target: "/assets/app.wasm",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, tt.opts)
+			r.GET("/assets/*file", func(c *gin.Context) {})
+
+			serve(r, http.MethodGet, tt.target)
+
+			if got := len(transport.Transactions()); got != tt.transactions {
+				t.Errorf("%d transactions have been reported, want %d", got, tt.transactions)
+			}
+		})
+	}
+
+	t.Run("panic", func(t *testing.T) {
+		r, transport := newRouter(t, Options{SkipStaticAssets: true})
+		r.GET("/assets/*file", func(c *gin.Context) {
+			panic("asset not found")
+		})
+
+		serve(r, http.MethodGet, "/assets/logo.png")
+
+		if got := onlyEvent(t, transport).Message; got != "asset not found" {
+			t.Errorf("event message = %q, want %q", got, "asset not found")
+		}
+		if got := len(transport.Transactions()); got != 0 {
+			t.Errorf("%d transactions have been reported, want 0", got)
+		}
+	})
+}
+
+func TestRecordContentNegotiation(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(strconv.FormatBool(enabled), func(t *testing.T) {
+			r, transport := newRouter(t, Options{RecordContentNegotiation: enabled})
+			r.GET("/users/:id", func(c *gin.Context) {
+				// the legacy XML representation isn't served anymore, the clients asking for it fall back to JSON
+				c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			req.Header.Set("Accept", "application/xml")
+			serveRequest(r, req)
+
+			data := onlyTransaction(t, transport).Extra
+			want := map[string]interface{}{
+				"http.request.accept":        "application/xml",
+				"http.response.content_type": "application/json; charset=utf-8",
+			}
+			for key, value := range want {
+				got, ok := data[key]
+				if enabled && got != value {
+					t.Errorf("%s = %v, want %v", key, got, value)
+				}
+				if !enabled && ok {
+					t.Errorf("%s = %v has been recorded", key, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAfterRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		status  sentry.SpanStatus
+	}{
+		{name: "ok", handler: func(c *gin.Context) {}, status: sentry.SpanStatusOK},
+		{name: "panic", handler: func(c *gin.Context) { panic("user not found") }, status: sentry.SpanStatusInternalError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			var status sentry.SpanStatus
+			r, transport := newRouter(t, Options{
+				AfterRequest: func(c *gin.Context, span *sentry.Span) {
+					calls++
+					status = span.Status
+					span.SetTag("after_request", "true")
+				},
+			})
+			r.GET("/users/:id", tt.handler)
+
+			serve(r, http.MethodGet, "/users/1")
+
+			if calls != 1 {
+				t.Errorf("AfterRequest has been called %d times, want 1", calls)
+			}
+			if status != tt.status {
+				t.Errorf("span status = %v, want %v", status, tt.status)
+			}
+			if got := onlyTransaction(t, transport).Tags["after_request"]; got != "true" {
+				t.Errorf("after_request tag = %q, want %q", got, "true")
+			}
+		})
+	}
+}
+
+func TestBypassWhen(t *testing.T) {
+	var reached bool
+	r, transport := newRouter(t, Options{
+		BypassWhen: func(c *gin.Context) bool {
+			return c.GetHeader("X-Health-Probe") != ""
+		},
+	})
+	r.GET("/healthz", func(c *gin.Context) {
+		reached = true
+		if _, ok := c.Get(HubKey); ok {
+			t.Error("a hub has been set for a bypassed request")
+		}
+		if sentry.HasHubOnContext(c.Request.Context()) {
+			t.Error("a hub has been set on the context of a bypassed request")
+		}
+	})
+	r.GET("/users", func(c *gin.Context) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Health-Probe", "kubelet")
+	serveRequest(r, req)
+
+	if !reached {
+		t.Fatal("the handler of a bypassed request hasn't been called")
+	}
+	if events := transport.Events(); len(events) != 0 {
+		t.Errorf("%d events have been reported for a bypassed request, want 0", len(events))
+	}
+	if transactions := transport.Transactions(); len(transactions) != 0 {
+		t.Errorf("%d transactions have been reported for a bypassed request, want 0", len(transactions))
+	}
+
+	serve(r, http.MethodGet, "/users")
+	onlyTransaction(t, transport)
+}
+
+func TestStructuredValidationErrors(t *testing.T) {
+	type createUser struct {
+		Name  string `json:"name" binding:"required"`
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	r, transport := newRouter(t, Options{CaptureErrors: true, StructuredValidationErrors: true})
+	r.POST("/users", func(c *gin.Context) {
+		var req createUser
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(err)
+			c.Status(http.StatusBadRequest)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"email":"not-an-email"}`))
+	req.Header.Set("Content-Type", "application/json")
+	serveRequest(r, req)
+
+	validation := onlyEvent(t, transport).Contexts["validation"]
+	want := map[string]interface{}{
+		"createUser.Name":  "required",
+		"createUser.Email": "email",
+	}
+	if !reflect.DeepEqual(map[string]interface{}(validation), want) {
+		t.Errorf("validation context = %v, want %v", validation, want)
+	}
+}
+
+func TestDetectDoubleRegistration(t *testing.T) {
+	var logs bytes.Buffer
+	hub, transport := newTestHub(t)
+	opts := Options{
+		DetectDoubleRegistration: true,
+		Logger:                   log.New(&logs, "", 0),
+	}
+	r := gin.New()
+	// e.g. registered globally and again on a group
+	r.Use(NewWithClient(hub.Client(), opts))
+	users := r.Group("/users", NewWithClient(hub.Client(), opts))
+	users.GET("/:id", func(c *gin.Context) {
+		panic("user not found")
+	})
+
+	for i := 0; i < 2; i++ {
+		serve(r, http.MethodGet, "/users/1")
+	}
+
+	if events := transport.Events(); len(events) != 2 {
+		t.Errorf("%d events have been reported, want 1 per request", len(events))
+	}
+	if transactions := transport.Transactions(); len(transactions) != 2 {
+		t.Errorf("%d transactions have been reported, want 1 per request", len(transactions))
+	}
+	if got := strings.Count(logs.String(), "registered twice"); got != 1 {
+		t.Errorf("the double registration has been logged %d times, want 1:\n%s", got, logs.String())
+	}
+}
+
+func TestResponseHeaderTags(t *testing.T) {
+	r, transport := newRouter(t, Options{
+		ResponseHeaderTags: map[string]string{"X-Cache": "cache", "X-Served-By": "served_by", "X-Debug": "debug"},
+	})
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Header("X-Cache", "HIT")
+		c.Header("X-Debug", strings.Repeat("a", maxTagValueLength+1))
+		c.Status(http.StatusOK)
+	})
+
+	serve(r, http.MethodGet, "/users/1")
+
+	tags := onlyTransaction(t, transport).Tags
+	if got := tags["cache"]; got != "HIT" {
+		t.Errorf("cache tag = %q, want %q", got, "HIT")
+	}
+	if got, ok := tags["served_by"]; ok {
+		t.Errorf("served_by tag = %q has been set for a missing header", got)
+	}
+	if _, ok := tags["debug"]; ok {
+		t.Error("debug tag has been set for a value longer than a tag")
+	}
+}
+
+type spanContextKey struct{}
+
+func TestTraceContextFromRequest(t *testing.T) {
+	sc := SpanContext{
+		TraceID: sentry.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  sentry.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		Sampled: sentry.SampledTrue,
+	}
+
+	tests := []struct {
+		name        string
+		ctx         interface{}
+		traceparent string
+		traceID     string
+		parent      string
+	}{
+		{name: "span", ctx: sc, traceID: sc.TraceID.String(), parent: sc.SpanID.String()},
+		{name: "no span"},
+		{name: "invalid span", ctx: SpanContext{TraceID: sc.TraceID}},
+		{
+			name:        "takes precedence over traceparent",
+			ctx:         sc,
+			traceparent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			traceID:     sc.TraceID.String(),
+			parent:      sc.SpanID.String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{
+				ContinueFromTraceparent: true,
+				TraceContextFromRequest: func(r *http.Request) (SpanContext, bool) {
+					sc, ok := r.Context().Value(spanContextKey{}).(SpanContext)
+					return sc, ok
+				},
+			})
+			r.GET("/users", func(c *gin.Context) {})
+
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.ctx != nil {
+				req = req.WithContext(context.WithValue(req.Context(), spanContextKey{}, tt.ctx))
+			}
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			serveRequest(r, req)
+
+			trace := onlyTransaction(t, transport).Contexts["trace"]
+			gotTraceID := trace["trace_id"].(sentry.TraceID).String()
+			if tt.traceID != "" && gotTraceID != tt.traceID {
+				t.Errorf("trace ID = %s, want %s", gotTraceID, tt.traceID)
+			}
+			if tt.traceID == "" && gotTraceID == sc.TraceID.String() {
+				t.Error("the trace of an invalid span context has been continued")
+			}
+			parent, ok := trace["parent_span_id"].(sentry.SpanID)
+			if tt.parent == "" && ok {
+				t.Errorf("parent span ID = %s, want none", parent)
+			}
+			if tt.parent != "" && parent.String() != tt.parent {
+				t.Errorf("parent span ID = %s, want %s", parent, tt.parent)
+			}
+		})
+	}
+}
+
+type orderPanic struct {
+	orderID int
+}
+
+type orderError struct {
+	orderID int
+}
+
+func (e *orderError) Error() string {
+	return "order " + strconv.Itoa(e.orderID) + " is invalid"
+}
+
+func TestPanicToError(t *testing.T) {
+	var onRecover interface{}
+	r, transport := newRouter(t, Options{
+		PanicToError: func(recovered interface{}) error {
+			if p, ok := recovered.(orderPanic); ok {
+				return &orderError{orderID: p.orderID}
+			}
+			return nil
+		},
+		OnRecover: func(c *gin.Context, recovered interface{}, eventID *sentry.EventID) {
+			onRecover = recovered
+		},
+	})
+	r.GET("/orders/:id", func(c *gin.Context) {
+		panic(orderPanic{orderID: 42})
+	})
+	r.GET("/users/:id", func(c *gin.Context) {
+		panic("user not found")
+	})
+
+	serve(r, http.MethodGet, "/orders/42")
+
+	event := onlyEvent(t, transport)
+	if len(event.Exception) == 0 {
+		t.Fatalf("the event has no exception, message = %q", event.Message)
+	}
+	exception := event.Exception[len(event.Exception)-1]
+	if exception.Type != "*sentrygin.orderError" {
+		t.Errorf("exception type = %q, want %q", exception.Type, "*sentrygin.orderError")
+	}
+	if exception.Value != "order 42 is invalid" {
+		t.Errorf("exception value = %q, want %q", exception.Value, "order 42 is invalid")
+	}
+	if onRecover != (orderPanic{orderID: 42}) {
+		t.Errorf("OnRecover got %#v, want the original value", onRecover)
+	}
+
+	transport.Reset()
+	serve(r, http.MethodGet, "/users/1")
+
+	if got := onlyEvent(t, transport).Message; got != "user not found" {
+		t.Errorf("message = %q, want the unconverted panic to be reported as is", got)
+	}
+}
+
+// transactionProfile returns the profile attached to a transaction, which is not exported by sentry.Event.
+func transactionProfile(event *sentry.Event) reflect.Value {
+	return reflect.ValueOf(event).Elem().FieldByName("sdkMetaData").FieldByName("transactionProfile")
+}
+
+func TestEnableProfiling(t *testing.T) {
+	t.Run("profiled", func(t *testing.T) {
+		hub, transport, err := testtransport.NewHub(sentry.ClientOptions{ProfilesSampleRate: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var logs bytes.Buffer
+		r := gin.New()
+		r.Use(NewWithClient(hub.Client(), Options{EnableProfiling: true, Logger: log.New(&logs, "", 0)}))
+		r.GET("/reports/:id", func(c *gin.Context) {
+			// the profiler samples the goroutines every 10ms
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		serve(r, http.MethodGet, "/reports/1")
+
+		if profile := transactionProfile(onlyTransaction(t, transport)); profile.IsNil() {
+			t.Error("no profile has been attached to the transaction")
+		}
+		if logs.Len() != 0 {
+			t.Errorf("unexpected warning: %s", logs.String())
+		}
+	})
+
+	t.Run("not profiled", func(t *testing.T) {
+		hub, transport := newTestHub(t)
+		var logs bytes.Buffer
+		r := gin.New()
+		r.Use(NewWithClient(hub.Client(), Options{EnableProfiling: true, Logger: log.New(&logs, "", 0)}))
+		r.GET("/reports/:id", func(c *gin.Context) {})
+
+		serve(r, http.MethodGet, "/reports/1")
+
+		if profile := transactionProfile(onlyTransaction(t, transport)); !profile.IsNil() {
+			t.Error("a profile has been attached to the transaction although ProfilesSampleRate is 0")
+		}
+		if !strings.Contains(logs.String(), "transactions won't be profiled") {
+			t.Errorf("no warning has been logged, got %q", logs.String())
+		}
+	})
+}
+
+func TestDist(t *testing.T) {
+	for _, reuse := range []bool{false, true} {
+		t.Run("ReuseHub="+strconv.FormatBool(reuse), func(t *testing.T) {
+			testDist(t, reuse)
+		})
+	}
+}
+
+// testDist checks that the Dist of a middleware doesn't leak into the current hub, which the hubs of requests
+// are cloned from (or which is reused with ReuseHub) by both middlewares.
+func testDist(t *testing.T, reuse bool) {
+	transport := bindCurrentHub(t)
+	routers := map[string]*gin.Engine{}
+	for _, dist := range []string{"web", "mobile"} {
+		r := gin.New()
+		r.Use(New(Options{Dist: dist, ReuseHub: reuse}))
+		r.GET("/users/:id", func(c *gin.Context) {
+			panic("user not found")
+		})
+		routers[dist] = r
+	}
+
+	for i := 0; i < 2; i++ {
+		for dist, r := range routers {
+			transport.Reset()
+			serve(r, http.MethodGet, "/users/1")
+
+			if got := onlyEvent(t, transport).Dist; got != dist {
+				t.Errorf("event dist = %q, want %q", got, dist)
+			}
+			if got := onlyTransaction(t, transport).Dist; got != dist {
+				t.Errorf("transaction dist = %q, want %q", got, dist)
+			}
+		}
+	}
+
+	transport.Reset()
+	sentry.CurrentHub().CaptureMessage("outside of a request")
+	if got := onlyEvent(t, transport).Dist; got != "" {
+		t.Errorf("dist of the current hub = %q, want none", got)
+	}
+}
+
+func TestNewWithClientNil(t *testing.T) {
+	global := bindCurrentHub(t)
+	var logs bytes.Buffer
+	r := gin.New()
+	r.Use(NewWithClient(nil, Options{WarnIfUninitialized: true, Logger: log.New(&logs, "", 0)}))
+	r.GET("/users/:id", func(c *gin.Context) {
+		if GetHubFromContext(c).Client() != nil {
+			t.Error("the hub of the request is bound to a client")
+		}
+		panic("user not found")
+	})
+
+	// the panic is still recovered
+	serve(r, http.MethodGet, "/users/1")
+
+	if got := len(global.Events()) + len(global.Transactions()); got != 0 {
+		t.Errorf("%d events have been reported to the global client, want none", got)
+	}
+	if !strings.Contains(logs.String(), "events won't be sent") {
+		t.Errorf("no warning has been logged, got %q", logs.String())
+	}
+}
+
+func TestWarnIfUninitialized(t *testing.T) {
+	unbindCurrentHub(t)
+	var logs bytes.Buffer
+	r := gin.New()
+	r.Use(New(Options{WarnIfUninitialized: true, Logger: log.New(&logs, "", 0)}))
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	for i := 0; i < 2; i++ {
+		rec := serve(r, http.MethodGet, "/users/1")
+		if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+			t.Errorf("response = %d %q, want 200 ok", rec.Code, rec.Body.String())
+		}
+	}
+
+	if got := strings.Count(logs.String(), "no client is bound"); got != 1 {
+		t.Errorf("the warning has been logged %d times, want 1:\n%s", got, logs.String())
+	}
+}
+
+func TestScrubHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]string
+	}{
+		{
+			name: "default",
+			want: map[string]string{
+				"Authorization": "[Filtered]",
+				"Cookie":        "[Filtered]",
+				"X-Api-Key":     "[Filtered]",
+				"X-Tenant":      "acme",
+			},
+		},
+		{
+			name: "custom",
+			opts: Options{ScrubHeaders: []string{"x-tenant"}},
+			want: map[string]string{
+				"Authorization": "Bearer secret",
+				"Cookie":        "session=secret",
+				"X-Api-Key":     "secret",
+				"X-Tenant":      "[Filtered]",
+			},
+		},
+		{
+			name: "disabled",
+			opts: Options{ScrubHeaders: []string{}},
+			want: map[string]string{
+				"Authorization": "Bearer secret",
+				"Cookie":        "session=secret",
+				"X-Api-Key":     "secret",
+				"X-Tenant":      "acme",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the SDK removes the Authorization and Cookie headers itself unless SendDefaultPII is set
+			// on the client of the current hub
+			hub, transport, err := testtransport.NewHub(sentry.ClientOptions{SendDefaultPII: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			previous := sentry.CurrentHub().Client()
+			sentry.CurrentHub().BindClient(hub.Client())
+			t.Cleanup(func() {
+				sentry.CurrentHub().BindClient(previous)
+			})
+			r := gin.New()
+			r.Use(New(tt.opts))
+			var seen http.Header
+			r.GET("/users/:id", func(c *gin.Context) {
+				seen = c.Request.Header.Clone()
+				panic("user not found")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("Cookie", "session=secret")
+			req.Header.Set("X-Api-Key", "secret")
+			req.Header.Set("X-Tenant", "acme")
+			serveRequest(r, req)
+
+			for _, event := range []*sentry.Event{onlyEvent(t, transport), onlyTransaction(t, transport)} {
+				for header, want := range tt.want {
+					if got := event.Request.Headers[header]; got != want {
+						t.Errorf("%s header of the %q event = %q, want %q", header, event.Type, got, want)
+					}
+				}
+				if got, want := event.Request.Cookies, tt.want["Cookie"]; got != want {
+					t.Errorf("cookies of the %q event = %q, want %q", event.Type, got, want)
+				}
+			}
+			for header, want := range map[string]string{
+				"Authorization": "Bearer secret",
+				"Cookie":        "session=secret",
+				"X-Api-Key":     "secret",
+				"X-Tenant":      "acme",
+			} {
+				if got := seen.Get(header); got != want {
+					t.Errorf("%s header seen by the handler = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBeforeCapturePanic(t *testing.T) {
+	hub, transport := newTestHub(t)
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	r.Use(NewWithClient(hub.Client(), Options{
+		Repanic: true,
+		BeforeCapture: func(c *gin.Context, hub *sentry.Hub, recovered interface{}) {
+			hub.Scope().SetTag("before_capture", "true")
+			panic("BeforeCapture failed")
+		},
+	}))
+	r.GET("/users/:id", func(c *gin.Context) {
+		panic("user not found")
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(r, http.MethodGet, "/users/1")
+	}()
+	var rec *httptest.ResponseRecorder
+	select {
+	case rec = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request hasn't completed")
+	}
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	event := onlyEvent(t, transport)
+	if event.Message != "user not found" {
+		t.Errorf("message = %q, want the recovered panic to be reported", event.Message)
+	}
+	if got := event.Tags["before_capture"]; got != "true" {
+		t.Errorf("before_capture tag = %q, want the changes made before the panic to be kept", got)
+	}
+	onlyTransaction(t, transport)
+}
+
+func TestAbortWithInternalError(t *testing.T) {
+	r, transport := newRouter(t, Options{AbortWithInternalError: true})
+	r.GET("/users/:id", func(c *gin.Context) {
+		panic("user not found")
+	})
+	r.GET("/reports/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("report failed")
+	})
+
+	// gin warns about status changes of written responses in debug mode only
+	var logs bytes.Buffer
+	writer := gin.DefaultWriter
+	gin.DefaultWriter = &logs
+	gin.SetMode(gin.DebugMode)
+	t.Cleanup(func() {
+		gin.SetMode(gin.TestMode)
+		gin.DefaultWriter = writer
+	})
+
+	rec := serve(r, http.MethodGet, "/users/1")
+	if rec.Code != http.StatusInternalServerError || rec.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want 500 without a body", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(r, http.MethodGet, "/reports/1")
+	if rec.Code != http.StatusOK || rec.Body.String() != "partial" {
+		t.Errorf("response = %d %q, want the written 200 partial to be kept", rec.Code, rec.Body.String())
+	}
+
+	if strings.Contains(logs.String(), "Headers were already written") {
+		t.Errorf("the written response has been overwritten:\n%s", logs.String())
+	}
+	if events := transport.Events(); len(events) != 2 {
+		t.Errorf("%d events have been reported, want 2", len(events))
+	}
+}
+
+func TestOnRecover(t *testing.T) {
+	type call struct {
+		recovered interface{}
+		eventID   *sentry.EventID
+		hub       *sentry.Hub
+	}
+	var calls []call
+	r, transport := newRouter(t, Options{
+		OnRecover: func(c *gin.Context, recovered interface{}, eventID *sentry.EventID) {
+			calls = append(calls, call{recovered: recovered, eventID: eventID, hub: GetHubFromContext(c)})
+		},
+	})
+	var hub *sentry.Hub
+	r.GET("/users/:id", func(c *gin.Context) {
+		hub = GetHubFromContext(c)
+		panic("user not found")
+	})
+	r.GET("/users", func(c *gin.Context) {})
+
+	serve(r, http.MethodGet, "/users")
+	if len(calls) != 0 {
+		t.Fatalf("OnRecover has been called %d times without a panic, want 0", len(calls))
+	}
+
+	serve(r, http.MethodGet, "/users/1")
+
+	if len(calls) != 1 {
+		t.Fatalf("OnRecover has been called %d times, want 1", len(calls))
+	}
+	if calls[0].recovered != "user not found" {
+		t.Errorf("recovered = %#v, want %q", calls[0].recovered, "user not found")
+	}
+	if calls[0].hub != hub {
+		t.Error("the hub of OnRecover isn't the hub of the request")
+	}
+	if event := onlyEvent(t, transport); calls[0].eventID == nil || *calls[0].eventID != event.EventID {
+		t.Errorf("event ID = %v, want %s", calls[0].eventID, event.EventID)
+	}
+}
+
+func TestIgnorePaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		traced bool
+	}{
+		{name: "exact", target: "/healthz"},
+		{name: "prefix", target: "/debug/pprof/heap"},
+		{name: "route", target: "/metrics/cpu"},
+		{name: "not ignored", target: "/users/1", traced: true},
+		{name: "exact only", target: "/healthz/deep", traced: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, transport := newRouter(t, Options{IgnorePaths: []string{"/healthz", "/debug/*", "/metrics/:name"}})
+			r.GET("/healthz", func(c *gin.Context) {})
+			r.GET("/healthz/deep", func(c *gin.Context) {})
+			r.GET("/debug/*path", func(c *gin.Context) {})
+			r.GET("/metrics/:name", func(c *gin.Context) {})
+			r.GET("/users/:id", func(c *gin.Context) {})
+
+			serve(r, http.MethodGet, tt.target)
+
+			transactions := len(transport.Transactions())
+			if tt.traced && transactions != 1 {
+				t.Errorf("%d transactions have been reported, want 1", transactions)
+			}
+			if !tt.traced && transactions != 0 {
+				t.Errorf("%d transactions have been reported for an ignored path, want 0", transactions)
+			}
+			if events := transport.Events(); len(events) != 0 {
+				t.Errorf("%d events have been reported, want 0", len(events))
+			}
+		})
+	}
+
+	t.Run("panic", func(t *testing.T) {
+		r, transport := newRouter(t, Options{IgnorePaths: []string{"/healthz"}})
+		r.GET("/healthz", func(c *gin.Context) {
+			panic("database unreachable")
+		})
+
+		serve(r, http.MethodGet, "/healthz")
+
+		if got := onlyEvent(t, transport).Message; got != "database unreachable" {
+			t.Errorf("message = %q, want the panic of an ignored path to be reported", got)
+		}
+		if transactions := transport.Transactions(); len(transactions) != 0 {
+			t.Errorf("%d transactions have been reported for an ignored path, want 0", len(transactions))
+		}
+	})
+}
+
+func TestSetTraceHeadersOnResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		baggage string
+	}{
+		{name: "enabled", opts: Options{SetTraceHeadersOnResponse: true}},
+		{name: "existing baggage", opts: Options{SetTraceHeadersOnResponse: true}, baggage: "tenant=acme"},
+		{name: "disabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport := newTestHub(t)
+			r := gin.New()
+			if tt.baggage != "" {
+				r.Use(func(c *gin.Context) {
+					c.Header("baggage", tt.baggage)
+				})
+			}
+			r.Use(NewWithClient(hub.Client(), tt.opts))
+			r.GET("/users/:id", func(c *gin.Context) {})
+
+			rec := serve(r, http.MethodGet, "/users/1")
+
+			trace := onlyTransaction(t, transport).Contexts["trace"]
+			sentryTrace, baggage := rec.Header().Get("sentry-trace"), rec.Header().Get("baggage")
+			if !tt.opts.SetTraceHeadersOnResponse {
+				if sentryTrace != "" || baggage != "" {
+					t.Errorf("trace headers = %q, %q, want none", sentryTrace, baggage)
+				}
+				return
+			}
+			if want := trace["trace_id"].(sentry.TraceID).String() + "-" + trace["span_id"].(sentry.SpanID).String() + "-1"; sentryTrace != want {
+				t.Errorf("sentry-trace = %q, want %q", sentryTrace, want)
+			}
+			switch {
+			case tt.baggage != "" && baggage != tt.baggage:
+				t.Errorf("baggage = %q, want the existing %q", baggage, tt.baggage)
+			case tt.baggage == "" && !strings.Contains(baggage, "sentry-trace_id="+trace["trace_id"].(sentry.TraceID).String()):
+				t.Errorf("baggage = %q, want the dynamic sampling context of the transaction", baggage)
+			}
+		})
+	}
+}
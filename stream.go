@@ -0,0 +1,27 @@
+package sentrygin
+
+import (
+	"context"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"io"
+)
+
+// StreamSafe wraps c.Stream, recovering the panics of each step and reporting them using the hub of the request,
+// so streaming handlers (e.g. SSE) report panics the same way the other handlers do.
+// A panicking step ends the stream, as the response has already been partially written by then.
+// It returns the result of c.Stream, i.e. whether the client disconnected in the middle of the stream.
+func StreamSafe(c *gin.Context, step func(w io.Writer) bool) bool {
+	return c.Stream(func(w io.Writer) (keepOpen bool) {
+		defer func() {
+			if err := recover(); err != nil {
+				hub := GetHubFromContext(c)
+				ctx := context.WithValue(c.Request.Context(), sentry.RequestContextKey, c.Request)
+				hub.RecoverWithContext(ctx, err)
+				keepOpen = false
+			}
+		}()
+
+		return step(w)
+	})
+}
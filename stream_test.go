@@ -0,0 +1,49 @@
+package sentrygin
+
+import (
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamSafe(t *testing.T) {
+	r, transport := newRouter(t, Options{})
+	done := make(chan bool, 1)
+	r.GET("/events", func(c *gin.Context) {
+		steps := 0
+		done <- StreamSafe(c, func(w io.Writer) bool {
+			steps++
+			if steps == 3 {
+				panic("stream failed")
+			}
+			c.SSEvent("step", steps)
+			return true
+		})
+	})
+	// c.Stream requires a http.CloseNotifier, which httptest.ResponseRecorder doesn't implement.
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if disconnected := <-done; disconnected {
+		t.Error("StreamSafe reported a disconnection, want the stream to be ended by the panic")
+	}
+	if want := "event:step\ndata:1\n\nevent:step\ndata:2\n\n"; string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+	event := onlyEvent(t, transport)
+	if event.Message != "stream failed" {
+		t.Errorf("message = %q, want the panic of the step", event.Message)
+	}
+	if event.Request == nil || event.Request.URL == "" {
+		t.Errorf("request = %+v, want the request of the stream", event.Request)
+	}
+}
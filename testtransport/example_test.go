@@ -0,0 +1,32 @@
+package testtransport_test
+
+import (
+	"fmt"
+	"github.com/Kichiyaki/sentrygin"
+	"github.com/Kichiyaki/sentrygin/testtransport"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+)
+
+func Example() {
+	hub, transport, err := testtransport.NewHub(sentry.ClientOptions{})
+	if err != nil {
+		panic(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sentrygin.NewWithClient(hub.Client(), sentrygin.Options{}))
+	r.GET("/users/:id", func(c *gin.Context) {
+		panic("user not found")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	fmt.Println(transport.Transactions()[0].Transaction)
+	fmt.Println(transport.Events()[0].Message)
+	// Output:
+	// GET /users/:id
+	// user not found
+}
@@ -0,0 +1,107 @@
+// Package testtransport provides an in-memory sentry.Transport for testing the integration of sentrygin
+// with httptest:
+//
+//	hub, transport, err := testtransport.NewHub(sentry.ClientOptions{})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	r := gin.New()
+//	r.Use(sentrygin.NewWithClient(hub.Client(), sentrygin.Options{}))
+//	r.GET("/users/:id", handler)
+//	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+//
+//	if got := transport.Transactions()[0].Transaction; got != "GET /users/:id" {
+//		t.Errorf("transaction name = %q", got)
+//	}
+package testtransport
+
+import (
+	"github.com/getsentry/sentry-go"
+	"sync"
+	"time"
+)
+
+// transactionType is the type of the events of transactions.
+const transactionType = "transaction"
+
+// Transport is a sentry.Transport collecting the sent events in memory, it's safe for concurrent use.
+type Transport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+// New returns an empty Transport.
+func New() *Transport {
+	return &Transport{}
+}
+
+// NewHub returns a hub bound to a new client sending its events to the returned Transport.
+// Tracing is always enabled, as the SDK drops every transaction otherwise even if opts sets a sample rate,
+// with a sample rate of 1 unless opts configures the sampling of transactions.
+func NewHub(opts sentry.ClientOptions) (*sentry.Hub, *Transport, error) {
+	transport := New()
+	opts.Transport = transport
+	opts.EnableTracing = true
+	if opts.TracesSampleRate == 0 && opts.TracesSampler == nil {
+		opts.TracesSampleRate = 1
+	}
+
+	client, err := sentry.NewClient(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sentry.NewHub(client, sentry.NewScope()), transport, nil
+}
+
+// Configure implements sentry.Transport.
+func (t *Transport) Configure(sentry.ClientOptions) {}
+
+// SendEvent implements sentry.Transport, it stores the event.
+func (t *Transport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, event)
+}
+
+// Flush implements sentry.Transport, the events are stored synchronously so there's nothing to wait for.
+func (t *Transport) Flush(time.Duration) bool {
+	return true
+}
+
+// Events returns the events sent so far, except transactions, in the order they were sent.
+func (t *Transport) Events() []*sentry.Event {
+	return t.filter(func(event *sentry.Event) bool {
+		return event.Type != transactionType
+	})
+}
+
+// Transactions returns the transactions sent so far, in the order they were sent.
+func (t *Transport) Transactions() []*sentry.Event {
+	return t.filter(func(event *sentry.Event) bool {
+		return event.Type == transactionType
+	})
+}
+
+// Reset drops the events sent so far.
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = nil
+}
+
+func (t *Transport) filter(keep func(event *sentry.Event) bool) []*sentry.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var events []*sentry.Event
+	for _, event := range t.events {
+		if keep(event) {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
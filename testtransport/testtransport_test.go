@@ -0,0 +1,51 @@
+package testtransport
+
+import (
+	"context"
+	"github.com/getsentry/sentry-go"
+	"testing"
+)
+
+func TestNewHub(t *testing.T) {
+	tests := []struct {
+		name string
+		opts sentry.ClientOptions
+		want int
+	}{
+		{name: "default", want: 3},
+		{name: "sample rate", opts: sentry.ClientOptions{TracesSampleRate: 1}, want: 3},
+		{
+			name: "sampler",
+			opts: sentry.ClientOptions{TracesSampler: func(ctx sentry.SamplingContext) float64 {
+				return 0
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, transport, err := NewHub(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := sentry.SetHubOnContext(context.Background(), hub)
+			for i := 0; i < 3; i++ {
+				sentry.StartSpan(ctx, "test", sentry.WithTransactionName("test")).Finish()
+			}
+			hub.CaptureMessage("message")
+
+			if got := len(transport.Transactions()); got != tt.want {
+				t.Errorf("got %d transactions, want %d", got, tt.want)
+			}
+			if got := len(transport.Events()); got != 1 {
+				t.Errorf("got %d events, want 1", got)
+			}
+
+			transport.Reset()
+			if got := len(transport.Events()) + len(transport.Transactions()); got != 0 {
+				t.Errorf("got %d events after Reset, want none", got)
+			}
+		})
+	}
+}
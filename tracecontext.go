@@ -0,0 +1,29 @@
+package sentrygin
+
+import "github.com/getsentry/sentry-go"
+
+// SpanContext identifies the span continued by a request, it's decoded from TraceContextHeader
+// by DecodeTraceContext or returned by TraceContextFromRequest.
+type SpanContext struct {
+	TraceID sentry.TraceID
+	SpanID  sentry.SpanID
+	// Sampled is the sampling decision of the trace, the sampler of the SDK decides if it's sentry.SampledUndefined.
+	Sampled sentry.Sampled
+}
+
+// sentryTrace returns the sentry-trace header of the span context, or false if it doesn't identify a span.
+func (sc SpanContext) sentryTrace() (string, bool) {
+	if sc.TraceID == (sentry.TraceID{}) || sc.SpanID == (sentry.SpanID{}) {
+		return "", false
+	}
+
+	trace := sc.TraceID.String() + "-" + sc.SpanID.String()
+	switch sc.Sampled {
+	case sentry.SampledTrue:
+		trace += "-1"
+	case sentry.SampledFalse:
+		trace += "-0"
+	}
+
+	return trace, true
+}
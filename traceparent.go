@@ -0,0 +1,43 @@
+package sentrygin
+
+import "strings"
+
+const traceparentHeader = "traceparent"
+
+// sentryTraceFromTraceparent converts a W3C traceparent header (https://www.w3.org/TR/trace-context/#traceparent-header)
+// into a sentry-trace header without a sampling decision.
+func sentryTraceFromTraceparent(traceparent string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return "", false
+	}
+
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", false
+	}
+	if !isHex(traceID, 32) || isZero(traceID) || !isHex(parentID, 16) || isZero(parentID) || !isHex(flags, 2) {
+		return "", false
+	}
+
+	return traceID + "-" + parentID, true
+}
+
+// isHex reports whether s consists of n lowercase hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
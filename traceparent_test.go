@@ -0,0 +1,70 @@
+package sentrygin
+
+import "testing"
+
+func TestSentryTraceFromTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		want        string
+		ok          bool
+	}{
+		{
+			name:        "valid",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:        "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			ok:          true,
+		},
+		{
+			name:        "surrounding spaces",
+			traceparent: " 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00 ",
+			want:        "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			ok:          true,
+		},
+		{
+			name:        "future version with extra fields",
+			traceparent: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-holds",
+			want:        "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			ok:          true,
+		},
+		{name: "empty"},
+		{name: "garbage", traceparent: "not-a-traceparent"},
+		{
+			name:        "version 00 with extra fields",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		},
+		{
+			name:        "invalid version",
+			traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "uppercase trace ID",
+			traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "short trace ID",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "zero trace ID",
+			traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "zero parent ID",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		},
+		{
+			name:        "invalid flags",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-x1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sentryTraceFromTraceparent(tt.traceparent)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("sentryTraceFromTraceparent(%q) = %q, %t, want %q, %t", tt.traceparent, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
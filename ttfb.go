@@ -0,0 +1,39 @@
+package sentrygin
+
+import (
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// ttfbWriter records the time elapsed between start and the first write of the response.
+type ttfbWriter struct {
+	gin.ResponseWriter
+	start time.Time
+	ttfb  time.Duration
+}
+
+func (w *ttfbWriter) record() {
+	if w.ttfb == 0 {
+		w.ttfb = time.Since(w.start)
+	}
+}
+
+func (w *ttfbWriter) Write(data []byte) (int, error) {
+	w.record()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *ttfbWriter) WriteString(s string) (int, error) {
+	w.record()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *ttfbWriter) WriteHeaderNow() {
+	w.record()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *ttfbWriter) Flush() {
+	w.record()
+	w.ResponseWriter.Flush()
+}
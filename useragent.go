@@ -0,0 +1,50 @@
+package sentrygin
+
+import (
+	"net/url"
+	"strings"
+)
+
+// userAgentFamilies maps substrings of user agents to their families, in the order they are checked,
+// e.g. Edge and Opera user agents mention Chrome as well, which mentions Safari.
+var userAgentFamilies = []struct {
+	token  string
+	family string
+}{
+	{"bot", "Bot"},
+	{"spider", "Bot"},
+	{"crawl", "Bot"},
+	{"curl/", "curl"},
+	{"go-http-client", "Go"},
+	{"edg", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"firefox", "Firefox"},
+	{"chrome", "Chrome"},
+	{"chromium", "Chrome"},
+	{"crios", "Chrome"},
+	{"safari", "Safari"},
+}
+
+// UserAgentFamily returns the coarse family of a user agent (e.g. Chrome, Firefox, Safari, Bot), or Other.
+// It's the default normalization of the user_agent tag, see TagUserAgent.
+func UserAgentFamily(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	for _, f := range userAgentFamilies {
+		if strings.Contains(ua, f.token) {
+			return f.family
+		}
+	}
+
+	return "Other"
+}
+
+// refererHost returns the host of a Referer header value, falling back to the whole value
+// when it can't be parsed as an absolute URL.
+func refererHost(referer string) string {
+	if u, err := url.Parse(referer); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return referer
+}
@@ -0,0 +1,68 @@
+package sentrygin
+
+import "testing"
+
+func TestUserAgentFamily(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36",
+			want:      "Chrome",
+		},
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36 Edg/116.0.1938.69",
+			want:      "Edge",
+		},
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36 OPR/102.0.0.0",
+			want:      "Opera",
+		},
+		{
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/117.0",
+			want:      "Firefox",
+		},
+		{
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 13_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Safari/605.1.15",
+			want:      "Safari",
+		},
+		{
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/116.0.5845.118 Mobile/15E148 Safari/604.1",
+			want:      "Chrome",
+		},
+		{
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:      "Bot",
+		},
+		{userAgent: "curl/8.1.2", want: "curl"},
+		{userAgent: "Go-http-client/1.1", want: "Go"},
+		{userAgent: "PostmanRuntime/7.32.3", want: "Other"},
+		{userAgent: "", want: "Other"},
+	}
+
+	for _, tt := range tests {
+		if got := UserAgentFamily(tt.userAgent); got != tt.want {
+			t.Errorf("UserAgentFamily(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestRefererHost(t *testing.T) {
+	tests := []struct {
+		referer string
+		want    string
+	}{
+		{referer: "https://www.google.com/search?q=gin", want: "www.google.com"},
+		{referer: "http://localhost:3000/login", want: "localhost:3000"},
+		{referer: "android-app://com.example.app/", want: "com.example.app"},
+		{referer: "not a url", want: "not a url"},
+		{referer: "/relative/path", want: "/relative/path"},
+	}
+
+	for _, tt := range tests {
+		if got := refererHost(tt.referer); got != tt.want {
+			t.Errorf("refererHost(%q) = %q, want %q", tt.referer, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package sentrygin
+
+import (
+	"errors"
+	"github.com/getsentry/sentry-go"
+	"github.com/go-playground/validator/v10"
+)
+
+// validationContext flattens the validator.ValidationErrors in the chain of err into a context mapping
+// the namespaces of the invalid fields (e.g. User.Email) to the failed tags (e.g. required), or returns nil.
+func validationContext(err error) sentry.Context {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) == 0 {
+		return nil
+	}
+
+	ctx := make(sentry.Context, len(verrs))
+	for _, fe := range verrs {
+		ctx[fe.Namespace()] = fe.Tag()
+	}
+
+	return ctx
+}
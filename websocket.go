@@ -0,0 +1,47 @@
+package sentrygin
+
+import (
+	"bufio"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WebSocketMode configures how WebSocket upgrade requests are traced.
+type WebSocketMode int
+
+const (
+	// WebSocketDefault traces upgrade requests like any other request,
+	// so their transactions last until the connection is closed.
+	WebSocketDefault WebSocketMode = iota
+	// WebSocketSkip doesn't start transactions for upgrade requests, panics are still reported.
+	WebSocketSkip
+	// WebSocketShortSpan starts websocket.server transactions for upgrade requests,
+	// which are finished as soon as the connection is hijacked, i.e. once the handshake is done.
+	WebSocketShortSpan
+)
+
+// webSocketOperation is the operation of the transactions of upgrade requests in the WebSocketShortSpan mode.
+const webSocketOperation = "websocket.server"
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// hijackWriter calls onHijack once the connection has been hijacked.
+type hijackWriter struct {
+	gin.ResponseWriter
+	onHijack func()
+	hijacked bool
+}
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err == nil && !w.hijacked {
+		w.hijacked = true
+		w.onHijack()
+	}
+
+	return conn, rw, err
+}
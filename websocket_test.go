@@ -0,0 +1,83 @@
+package sentrygin
+
+import (
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newUpgradeRequest(target string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	return req
+}
+
+func TestWebSocketSkip(t *testing.T) {
+	r, transport := newRouter(t, Options{WebSocketMode: WebSocketSkip})
+	r.GET("/ws", func(c *gin.Context) {
+		panic("boom")
+	})
+	r.GET("/users", func(c *gin.Context) {})
+
+	serveRequest(r, newUpgradeRequest("/ws"))
+	serve(r, http.MethodGet, "/users")
+
+	if got := onlyTransaction(t, transport).Transaction; got != "GET /users" {
+		t.Errorf("transaction = %q, want the transaction of the plain request only", got)
+	}
+	if event := onlyEvent(t, transport); event.Message != "boom" {
+		t.Errorf("message = %q, want the panic of the upgrade request to be reported", event.Message)
+	}
+}
+
+func TestWebSocketShortSpan(t *testing.T) {
+	r, transport := newRouter(t, Options{WebSocketMode: WebSocketShortSpan, RecordDurationMeasurement: true})
+	sentBeforeClose := -1
+	r.GET("/ws", func(c *gin.Context) {
+		conn, rw, err := c.Writer.Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		// The transaction is sent once the connection is hijacked, rather than once it's closed.
+		sentBeforeClose = len(transport.Transactions())
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		_ = rw.Flush()
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req := newUpgradeRequest(srv.URL + "/ws")
+	req.RequestURI = ""
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, want 101", resp.StatusCode)
+	}
+	if sentBeforeClose != 1 {
+		t.Errorf("%d transactions sent before the connection was closed, want 1", sentBeforeClose)
+	}
+	transaction := onlyTransaction(t, transport)
+	if got := transaction.Contexts["trace"]["op"]; got != webSocketOperation {
+		t.Errorf("op = %v, want %s", got, webSocketOperation)
+	}
+	if got := transaction.Contexts["trace"]["status"]; got != sentry.HTTPtoSpanStatus(http.StatusOK) {
+		t.Errorf("status = %v, want ok", got)
+	}
+	if got := transaction.Extra["http.response.status_code"]; got != http.StatusSwitchingProtocols {
+		t.Errorf("http.response.status_code = %v, want 101", got)
+	}
+	if _, ok := transaction.Extra["duration_ms"]; !ok {
+		t.Error("no duration_ms recorded")
+	}
+}